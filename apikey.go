@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/config"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+const apikeyUsage = `Usage: songgo apikey [flags] <command>
+
+Commands:
+  create -name NAME [-role reader|editor|admin] [-tenant SLUG | -all-tenants]
+                     create a key and print it once
+  list               list keys without the secrets
+  revoke ID          revoke a key
+`
+
+// runAPIKey управляет API-ключами в базе данных. Ключ выводится только при
+// создании; в базе хранится его хеш. С -backend memory create только выводит
+// ключ и хеш для параметра api_keys.
+func runAPIKey(args []string) int {
+	fs := flag.NewFlagSet("apikey", flag.ExitOnError)
+	name := fs.String("name", "", "key name, e.g. the client it is issued to (create)")
+	roleName := fs.String("role", string(auth.RoleReader), "key role: reader, editor or admin (create)")
+	tenantSlug := fs.String("tenant", tenant.DefaultSlug, "tenant the key is bound to (create)")
+	allTenants := fs.Bool("all-tenants", false, "do not bind the key to a tenant, it selects one with X-Tenant (create)")
+	backend := fs.String("backend", "", "storage: postgres or memory (default: the configured storage)")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), apikeyUsage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command, args := args[0], args[1:]
+	if *backend == "" {
+		*backend = cfg.StorageBackend
+	}
+
+	switch {
+	case command == "create" && (*name == "" || len(args) != 0):
+		fmt.Fprintln(os.Stderr, "apikey create: -name is required")
+		return 2
+	case command == "revoke" && len(args) != 1:
+		fmt.Fprint(os.Stderr, apikeyUsage)
+		return 2
+	case command != "create" && command != "list" && command != "revoke":
+		fmt.Fprintf(os.Stderr, "unknown apikey command %q\n\n%s", command, apikeyUsage)
+		return 2
+	}
+
+	role, err := auth.ParseRole(*roleName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apikey: %v\n", err)
+		return 2
+	}
+
+	if *allTenants {
+		tenantSet := false
+		fs.Visit(func(f *flag.Flag) { tenantSet = tenantSet || f.Name == "tenant" })
+		if tenantSet {
+			fmt.Fprintln(os.Stderr, "apikey: -tenant and -all-tenants are mutually exclusive")
+			return 2
+		}
+		*tenantSlug = ""
+	} else if !tenant.ValidSlug(*tenantSlug) {
+		fmt.Fprintf(os.Stderr, "apikey: invalid tenant %q\n", *tenantSlug)
+		return 2
+	}
+
+	if *backend == config.BackendMemory {
+		if command != "create" {
+			fmt.Fprintln(os.Stderr, "apikey: the memory storage keeps keys in the api_keys setting, only create is supported")
+			return 2
+		}
+		key, _, hash, err := auth.GenerateKey()
+		if err != nil {
+			cliLogger.Error("failed to generate a key", "error", err)
+			return 1
+		}
+		value := string(role) + ":" + hash
+		if *tenantSlug != "" {
+			value = *tenantSlug + ":" + value
+		}
+		fmt.Printf("key:  %s\nhash: %s\n\nadd to the config file:\n  api_keys:\n    %s: %s\n", key, hash, *name, value)
+		return 0
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+	store := auth.NewPostgresStore(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch command {
+	case "create":
+		key, prefix, hash, err := auth.GenerateKey()
+		if err == nil {
+			var created *auth.Key
+			created, err = store.Create(ctx, *name, prefix, hash, role, *tenantSlug)
+			if err == nil {
+				fmt.Printf("id:     %d\nname:   %s\nrole:   %s\ntenant: %s\nkey:    %s\n\nThe key is shown only once, store it now.\n",
+					created.ID, created.Name, created.Role, formatTenant(created.Tenant), key)
+			}
+		}
+		if err != nil {
+			cliLogger.Error("failed to create the API key", "error", err)
+			return 1
+		}
+	case "list":
+		keys, err := store.List(ctx)
+		if err != nil {
+			cliLogger.Error("failed to list API keys", "error", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tROLE\tTENANT\tPREFIX\tCREATED\tLAST USED\tREVOKED")
+		for _, key := range keys {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Role, formatTenant(key.Tenant), key.Prefix,
+				key.CreatedAt.Format(time.RFC3339), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+		}
+		tw.Flush()
+	case "revoke":
+		id, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "apikey revoke: invalid ID %q\n", args[0])
+			return 2
+		}
+		err = store.Revoke(ctx, int32(id))
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			cliLogger.Error("API key not found or already revoked", "key_id", id)
+			return 1
+		}
+		if err != nil {
+			cliLogger.Error("failed to revoke the API key", "error", err)
+			return 1
+		}
+		fmt.Printf("revoked key %d\n", id)
+	}
+	return 0
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// formatTenant выводит "-" для ключа, не привязанного к клиенту.
+func formatTenant(slug string) string {
+	if slug == "" {
+		return "-"
+	}
+	return slug
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MethodJWT — аутентификация JWT, выпущенным SSO.
+const MethodJWT = "jwt"
+
+// Поддерживаемые алгоритмы подписи.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// Как часто можно перечитывать JWKS, встретив токен с незнакомым kid.
+const jwksReloadInterval = time.Minute
+
+// Минимальные размеры ключей: короче подпись легко подобрать.
+const (
+	minHMACKeySize = 32
+	minRSAKeyBits  = 2048
+)
+
+// JWTOptions — параметры проверки JWT.
+type JWTOptions struct {
+	// JWKSFile — путь к файлу JWKS с ключами проверки подписи.
+	JWKSFile string
+	// Issuer — ожидаемое значение iss.
+	Issuer string
+	// Audience — значение, которое должно быть в aud.
+	Audience string
+	// RoleClaim — claim со списком ролей; вложенные поля через точку,
+	// например realm_access.roles.
+	RoleClaim string
+	// RoleMap переводит роли SSO в роли сервиса. Значения без сопоставления
+	// принимаются, только если совпадают с названием роли сервиса.
+	RoleMap map[string]string
+	// TenantClaim — claim с идентификатором клиента. Токен без него может
+	// выбирать клиента заголовком X-Tenant.
+	TenantClaim string
+	// Leeway — допустимое расхождение часов при проверке exp и nbf.
+	Leeway time.Duration
+}
+
+// JWTVerifier проверяет подпись и claims JWT.
+type JWTVerifier struct {
+	opts   JWTOptions
+	parser *jwt.Parser
+
+	mu       sync.Mutex
+	keys     []verificationKey
+	loadedAt time.Time
+}
+
+// NewJWTVerifier загружает JWKS и создает проверку токенов.
+func NewJWTVerifier(opts JWTOptions) (*JWTVerifier, error) {
+	keys, err := loadJWKS(opts.JWKSFile)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTVerifier{
+		opts: opts,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256}),
+			jwt.WithIssuer(opts.Issuer),
+			jwt.WithAudience(opts.Audience),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(opts.Leeway),
+		),
+		keys:     keys,
+		loadedAt: time.Now(),
+	}, nil
+}
+
+// Verify проверяет токен и возвращает данные клиента. Роль — старшая из
+// ролей токена, известных сервису; если таких нет, Role пустая и любой
+// защищенный маршрут ответит 403.
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, err
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("token has no sub claim")
+	}
+
+	var role Role
+	for _, name := range claimStrings(claims, v.opts.RoleClaim) {
+		if mapped, ok := v.opts.RoleMap[name]; ok {
+			name = mapped
+		}
+		if r, err := ParseRole(name); err == nil && r.rank() > role.rank() {
+			role = r
+		}
+	}
+	tenant, _ := claims[v.opts.TenantClaim].(string)
+	return &Identity{Subject: subject, Method: MethodJWT, Role: role, Tenant: tenant, Claims: claims}, nil
+}
+
+// isJWT отличает JWT (три части через точку) от API-ключа, в котором точек нет.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (v *JWTVerifier) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	alg := t.Method.Alg()
+
+	key := v.findKey(kid, alg)
+	if key == nil && v.reload() {
+		// Ключи могли смениться: SSO выпускает токены новым ключом раньше,
+		// чем его добавят в закешированный JWKS
+		key = v.findKey(kid, alg)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("no %s key with kid %q", alg, kid)
+	}
+	return key, nil
+}
+
+// findKey ищет ключ по kid и алгоритму. Токен без kid подходит, только
+// если ключ с таким алгоритмом один.
+func (v *JWTVerifier) findKey(kid, alg string) any {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var found any
+	for _, k := range v.keys {
+		if k.alg != alg || (kid != "" && k.id != kid) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = k.key
+	}
+	return found
+}
+
+// reload перечитывает JWKS не чаще раза в jwksReloadInterval. Если файл
+// не читается, остаются прежние ключи.
+func (v *JWTVerifier) reload() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if time.Since(v.loadedAt) < jwksReloadInterval {
+		return false
+	}
+	v.loadedAt = time.Now()
+	keys, err := loadJWKS(v.opts.JWKSFile)
+	if err != nil {
+		logger.Error("failed to reload JWKS", "path", v.opts.JWKSFile, "error", err)
+		return false
+	}
+	v.keys = keys
+	logger.Info("JWKS reloaded", "path", v.opts.JWKSFile, "keys", len(keys))
+	return true
+}
+
+// claimStrings возвращает строковые значения claim по пути через точку.
+// Значение может быть строкой со списком через пробел или запятую либо
+// массивом строк.
+func claimStrings(claims jwt.MapClaims, path string) []string {
+	var value any = map[string]any(claims)
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = obj[part]
+	}
+
+	switch value := value.(type) {
+	case string:
+		return strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' })
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// verificationKey — ключ проверки подписи: []byte для HS256,
+// *rsa.PublicKey для RS256, *ecdsa.PublicKey для ES256.
+type verificationKey struct {
+	id  string
+	alg string
+	key any
+}
+
+// jwk — ключ в формате RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS читает ключи из файла JWKS. Ключи шифрования и ключи
+// неподдерживаемых алгоритмов пропускаются; если не осталось ни одного,
+// возвращается ошибка.
+func loadJWKS(path string) ([]verificationKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS %s: %w", path, err)
+	}
+
+	var keys []verificationKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS %s: key %d (kid %q): %w", path, i, k.Kid, err)
+		}
+		if key == nil {
+			logger.Warn("skipping unsupported JWKS key", "path", path, "kid", k.Kid, "kty", k.Kty, "alg", k.Alg)
+			continue
+		}
+		keys = append(keys, *key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s: no HS256, RS256 or ES256 signing keys", path)
+	}
+	return keys, nil
+}
+
+// verificationKey разбирает ключ. Для неподдерживаемого типа или
+// алгоритма возвращает nil без ошибки.
+func (k jwk) verificationKey() (*verificationKey, error) {
+	var alg string
+	var key any
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid k: %w", err)
+		}
+		if len(secret) < minHMACKeySize {
+			return nil, fmt.Errorf("HMAC key must be at least %d bytes", minHMACKeySize)
+		}
+		alg, key = AlgHS256, secret
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid e")
+		}
+		if n.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		alg, key = AlgRS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		// Точка должна лежать на кривой, иначе ключ небезопасен
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, errors.New("invalid P-256 point")
+		}
+		alg, key = AlgES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	default:
+		return nil, nil
+	}
+
+	if k.Alg != "" && k.Alg != alg {
+		return nil, nil
+	}
+	return &verificationKey{id: k.Kid, alg: alg, key: key}, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,276 @@
+// Package auth проверяет подлинность клиентов API.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+)
+
+var logger = logging.For(logging.ComponentAuth)
+
+// Все ключи начинаются с этого префикса, чтобы их было легко найти
+// в коде и логах и отличить от токенов других сервисов.
+const keyPrefix = "sgk_"
+
+// Сколько символов ключа после префикса сохраняется для опознания.
+const displayPrefixLength = 8
+
+// ErrKeyNotFound возвращается, если ключа нет или он отозван.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// Key — сведения об API-ключе. Сам ключ не хранится.
+type Key struct {
+	ID         int32      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Role       Role       `json:"role"`
+	Tenant     string     `json:"tenant,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// GenerateKey создает новый ключ. Возвращает сам ключ, который нужно
+// передать клиенту, его опознавательный префикс и хеш для хранения.
+func GenerateKey() (key, prefix, hash string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b[:])
+	return key, key[:len(keyPrefix)+displayPrefixLength], HashKey(key), nil
+}
+
+// HashKey возвращает SHA-256 ключа в hex. Ключи случайные и длинные,
+// поэтому медленный хеш для паролей не нужен, а поиск по хешу остается быстрым.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store хранит API-ключи.
+type Store interface {
+	// Lookup находит действующий ключ по хешу; ErrKeyNotFound, если его нет.
+	Lookup(ctx context.Context, hash string) (*Key, error)
+	// Touch отмечает использование ключа. Middleware вызывает его, только если
+	// ключ не использовался дольше touchInterval.
+	Touch(ctx context.Context, id int32) error
+	// Create сохраняет ключ. Ключ с tenant работает только с библиотекой
+	// этого клиента; пустой tenant позволяет выбирать клиента заголовком.
+	Create(ctx context.Context, name, prefix, hash string, role Role, tenant string) (*Key, error)
+	List(ctx context.Context) ([]Key, error)
+	// Revoke отзывает ключ; ErrKeyNotFound, если его нет или он уже отозван.
+	Revoke(ctx context.Context, id int32) error
+}
+
+// MemoryStore хранит ключи в памяти процесса. Используется с хранилищем
+// memory, ключи задаются в конфигурации хешами.
+type MemoryStore struct {
+	mu     sync.Mutex
+	keys   map[string]*Key
+	nextID int32
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]*Key), nextID: 1}
+}
+
+func (s *MemoryStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[hash]
+	if !ok || key.RevokedAt != nil {
+		return nil, ErrKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, id int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.ID == id {
+			now := time.Now()
+			key.LastUsedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Create(ctx context.Context, name, prefix, hash string, role Role, tenant string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := &Key{ID: s.nextID, Name: name, Prefix: prefix, Role: role, Tenant: tenant, CreatedAt: time.Now()}
+	s.nextID++
+	s.keys[hash] = key
+	created := *key
+	return &created, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, id int32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.ID == id && key.RevokedAt == nil {
+			now := time.Now()
+			key.RevokedAt = &now
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
+
+// PostgresStore хранит ключи в таблице api_keys.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{queries: database.New(db)}
+}
+
+func (s *PostgresStore) Lookup(ctx context.Context, hash string) (*Key, error) {
+	row, err := s.queries.GetActiveAPIKeyByHash(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keyFromRow(row.ApiKey, row.Tenant.String), nil
+}
+
+func (s *PostgresStore) Touch(ctx context.Context, id int32) error {
+	return s.queries.TouchAPIKey(ctx, id)
+}
+
+func (s *PostgresStore) Create(ctx context.Context, name, prefix, hash string, role Role, tenant string) (*Key, error) {
+	row, err := s.queries.CreateAPIKey(ctx, database.CreateAPIKeyParams{
+		Name: name, Prefix: prefix, KeyHash: hash, Role: string(role),
+		Tenant: sql.NullString{String: tenant, Valid: tenant != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("unknown tenant %q", tenant)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keyFromRow(row, tenant), nil
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.queries.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Key, len(rows))
+	for i, row := range rows {
+		keys[i] = *keyFromRow(row.ApiKey, row.Tenant.String)
+	}
+	return keys, nil
+}
+
+func (s *PostgresStore) Revoke(ctx context.Context, id int32) error {
+	revoked, err := s.queries.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func keyFromRow(row database.ApiKey, tenant string) *Key {
+	return &Key{
+		ID:         row.ID,
+		Name:       row.Name,
+		Prefix:     row.Prefix,
+		Role:       Role(row.Role),
+		Tenant:     tenant,
+		CreatedAt:  row.CreatedAt,
+		LastUsedAt: nullTime(row.LastUsedAt),
+		RevokedAt:  nullTime(row.RevokedAt),
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// StaticKey — ключ из конфигурации хранилища memory.
+type StaticKey struct {
+	Tenant string
+	Role   Role
+	Hash   string
+}
+
+// ParseStaticKey разбирает ключ из конфигурации хранилища memory:
+// "<tenant>:<role>:<sha256>", "<role>:<sha256>" или просто "<sha256>" —
+// тогда ключ получает роль admin. Ключ без клиента выбирает его заголовком.
+func ParseStaticKey(s string) (StaticKey, error) {
+	key := StaticKey{Role: RoleAdmin}
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 1:
+		key.Hash = parts[0]
+	case 2, 3:
+		role, err := ParseRole(parts[len(parts)-2])
+		if err != nil {
+			return StaticKey{}, err
+		}
+		key.Role, key.Hash = role, parts[len(parts)-1]
+		if len(parts) == 3 {
+			key.Tenant = parts[0]
+		}
+	default:
+		return StaticKey{}, errors.New(`expected "[tenant:][role:]hash"`)
+	}
+	if !ValidHash(key.Hash) {
+		return StaticKey{}, errors.New("expected a hex SHA-256 hash of the key")
+	}
+	key.Hash = strings.ToLower(key.Hash)
+	return key, nil
+}
+
+// ValidHash проверяет, что строка похожа на SHA-256 в hex.
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(strings.ToLower(hash))
+	return err == nil
+}
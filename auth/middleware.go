@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Способы аутентификации.
+const MethodAPIKey = "api_key"
+
+// Время последнего использования ключа обновляется не чаще этого интервала,
+// чтобы аутентификация не писала в базу на каждый запрос.
+const touchInterval = time.Minute
+
+// Identity — аутентифицированный клиент.
+type Identity struct {
+	// Subject — имя, под которым клиент записывается в логи.
+	Subject string
+	// KeyID — ID API-ключа; 0 для JWT.
+	KeyID int32
+	// Method — способ аутентификации.
+	Method string
+	// Role — уровень доступа.
+	Role Role
+	// Tenant — клиент, к которому привязаны учетные данные; пустая строка,
+	// если клиента можно выбрать заголовком X-Tenant.
+	Tenant string
+	// Claims — все claims JWT; nil для API-ключа.
+	Claims map[string]any
+}
+
+// Key — устойчивый идентификатор учетных данных: ID API-ключа или способ
+// аутентификации и subject JWT.
+func (id *Identity) Key() string {
+	if id.Method == MethodAPIKey {
+		return "key:" + strconv.Itoa(int(id.KeyID))
+	}
+	return id.Method + ":" + id.Subject
+}
+
+type identityKey struct{}
+
+// WithIdentity возвращает контекст с данными клиента.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext возвращает клиента, прошедшего аутентификацию, или nil.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Middleware пропускает только запросы с действующим API-ключом или JWT
+// в заголовке Authorization: Bearer и кладет данные клиента в контекст.
+// Если jwt равен nil, принимаются только API-ключи.
+func Middleware(store Store, jwt *JWTVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w, "", "missing API key: send Authorization: Bearer <key>")
+				return
+			}
+
+			if jwt != nil && isJWT(token) {
+				id, err := jwt.Verify(token)
+				if err != nil {
+					logger.WarnContext(r.Context(), "invalid JWT", "method", r.Method, "path", r.URL.Path,
+						"remote_addr", r.RemoteAddr, "error", err)
+					unauthorized(w, "invalid_token", "invalid token: "+err.Error())
+					return
+				}
+				logger.DebugContext(r.Context(), "authenticated", "subject", id.Subject, "auth_method", id.Method, "role", id.Role)
+				next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+				return
+			}
+
+			key, err := store.Lookup(r.Context(), HashKey(token))
+			if errors.Is(err, ErrKeyNotFound) {
+				logger.WarnContext(r.Context(), "invalid API key", "method", r.Method, "path", r.URL.Path,
+					"remote_addr", r.RemoteAddr)
+				unauthorized(w, "invalid_token", "invalid or revoked API key")
+				return
+			}
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to look up API key", "error", err)
+				http.Error(w, "failed to verify credentials", http.StatusInternalServerError)
+				return
+			}
+
+			if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= touchInterval {
+				if err := store.Touch(r.Context(), key.ID); err != nil {
+					logger.WarnContext(r.Context(), "failed to record API key use", "key_id", key.ID, "error", err)
+				}
+			}
+			id := &Identity{Subject: key.Name, KeyID: key.ID, Method: MethodAPIKey, Role: key.Role, Tenant: key.Tenant}
+			logger.DebugContext(r.Context(), "authenticated", "subject", id.Subject, "key_id", id.KeyID, "role", id.Role)
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter, errorCode, msg string) {
+	challenge := `Bearer realm="songgo"`
+	if errorCode != "" {
+		challenge += `, error="` + errorCode + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
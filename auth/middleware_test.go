@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewarePutsKeyRoleIntoIdentity(t *testing.T) {
+	store := NewMemoryStore()
+	token, prefix, hash, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := store.Create(context.Background(), "partner-x", prefix, hash, RoleEditor, "acme")
+
+	var got *Identity
+	h := Middleware(store, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/songs", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got == nil {
+		t.Fatal("handler was not called with an identity")
+	}
+	if got.KeyID != key.ID || got.Role != RoleEditor || got.Tenant != "acme" || got.Method != MethodAPIKey {
+		t.Fatalf("identity = %+v", got)
+	}
+}
+
+func TestMiddlewareRejectsMissingAndRevokedKeys(t *testing.T) {
+	store := NewMemoryStore()
+	token, prefix, hash, _ := GenerateKey()
+	key, _ := store.Create(context.Background(), "old", prefix, hash, RoleAdmin, "")
+	store.Revoke(context.Background(), key.ID)
+
+	h := Middleware(store, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler called for an unauthenticated request")
+	}))
+	for name, header := range map[string]string{
+		"missing": "",
+		"unknown": "Bearer sg_unknown",
+		"revoked": "Bearer " + token,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/songs", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s key: status %d, WWW-Authenticate %q", name, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+)
+
+// Role — уровень доступа клиента. Каждая следующая роль включает права
+// предыдущей: reader читает, editor еще и изменяет песни, admin еще и удаляет
+// и управляет сервисом.
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+// Roles — все роли в порядке возрастания прав.
+var Roles = []Role{RoleReader, RoleEditor, RoleAdmin}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Includes сообщает, есть ли у роли права required.
+func (r Role) Includes(required Role) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+// ParseRole разбирает название роли.
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if role.rank() == 0 {
+		return "", fmt.Errorf("unknown role %q, expected reader, editor or admin", s)
+	}
+	return role, nil
+}
+
+var auditLogger = logging.For(logging.ComponentAudit)
+
+// Denial — запрос, отклоненный из-за недостаточной роли.
+type Denial struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Subject      string    `json:"subject" example:"partner-x"`
+	KeyID        int32     `json:"keyId,omitempty" example:"3"`
+	Role         Role      `json:"role" example:"reader"`
+	RequiredRole Role      `json:"requiredRole" example:"admin"`
+	Method       string    `json:"method" example:"DELETE"`
+	Path         string    `json:"path" example:"/songs/42"`
+	RemoteAddr   string    `json:"remoteAddr" example:"10.0.0.7:51234"`
+	RequestID    string    `json:"requestId"`
+}
+
+// AuditStore хранит журнал отклоненных запросов.
+type AuditStore interface {
+	Record(ctx context.Context, d Denial) error
+	// Recent возвращает последние limit записей, новые первыми.
+	Recent(ctx context.Context, limit int) ([]Denial, error)
+}
+
+// Сколько записей журнала держит MemoryAuditStore.
+const memoryAuditSize = 1000
+
+// MemoryAuditStore хранит последние записи журнала в памяти процесса.
+type MemoryAuditStore struct {
+	mu      sync.Mutex
+	denials []Denial
+	nextID  int64
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{nextID: 1}
+}
+
+func (s *MemoryAuditStore) Record(ctx context.Context, d Denial) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.ID = s.nextID
+	s.nextID++
+	s.denials = append(s.denials, d)
+	if len(s.denials) > memoryAuditSize {
+		s.denials = s.denials[len(s.denials)-memoryAuditSize:]
+	}
+	return nil
+}
+
+func (s *MemoryAuditStore) Recent(ctx context.Context, limit int) ([]Denial, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	denials := make([]Denial, 0, min(limit, len(s.denials)))
+	for i := len(s.denials) - 1; i >= 0 && len(denials) < limit; i-- {
+		denials = append(denials, s.denials[i])
+	}
+	return denials, nil
+}
+
+// PostgresAuditStore хранит журнал в таблице access_denials.
+type PostgresAuditStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{queries: database.New(db)}
+}
+
+func (s *PostgresAuditStore) Record(ctx context.Context, d Denial) error {
+	return s.queries.InsertAccessDenial(ctx, database.InsertAccessDenialParams{
+		Subject:      d.Subject,
+		KeyID:        sql.NullInt32{Int32: d.KeyID, Valid: d.KeyID != 0},
+		Role:         string(d.Role),
+		RequiredRole: string(d.RequiredRole),
+		Method:       d.Method,
+		Path:         d.Path,
+		RemoteAddr:   d.RemoteAddr,
+		RequestID:    d.RequestID,
+	})
+}
+
+func (s *PostgresAuditStore) Recent(ctx context.Context, limit int) ([]Denial, error) {
+	rows, err := s.queries.ListAccessDenials(ctx, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+	denials := make([]Denial, len(rows))
+	for i, row := range rows {
+		denials[i] = Denial{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			Subject:      row.Subject,
+			KeyID:        row.KeyID.Int32,
+			Role:         Role(row.Role),
+			RequiredRole: Role(row.RequiredRole),
+			Method:       row.Method,
+			Path:         row.Path,
+			RemoteAddr:   row.RemoteAddr,
+			RequestID:    row.RequestID,
+		}
+	}
+	return denials, nil
+}
+
+// Authorizer проверяет роль клиента и записывает отказы в журнал.
+type Authorizer struct {
+	audit   AuditStore
+	enabled bool
+}
+
+// NewAuthorizer создает проверку ролей. Если enabled равно false
+// (аутентификация отключена), все запросы разрешены.
+func NewAuthorizer(audit AuditStore, enabled bool) *Authorizer {
+	return &Authorizer{audit: audit, enabled: enabled}
+}
+
+// Require пропускает только клиентов с ролью не ниже role. Подключается
+// после Middleware.
+func (a *Authorizer) Require(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.Allow(w, r, role) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Allow проверяет роль клиента внутри обработчика. Если прав не хватает,
+// отвечает 403, записывает отказ в журнал и возвращает false.
+func (a *Authorizer) Allow(w http.ResponseWriter, r *http.Request, role Role) bool {
+	if a == nil || !a.enabled {
+		return true
+	}
+	id := FromContext(r.Context())
+	if id == nil {
+		unauthorized(w, "", "missing API key: send Authorization: Bearer <key>")
+		return false
+	}
+	if id.Role.Includes(role) {
+		return true
+	}
+
+	d := Denial{
+		CreatedAt:    time.Now(),
+		Subject:      id.Subject,
+		KeyID:        id.KeyID,
+		Role:         id.Role,
+		RequiredRole: role,
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		RemoteAddr:   r.RemoteAddr,
+		RequestID:    logging.RequestID(r.Context()),
+	}
+	auditLogger.WarnContext(r.Context(), "access denied",
+		"subject", d.Subject, "key_id", d.KeyID, "role", d.Role, "required_role", d.RequiredRole,
+		"method", d.Method, "path", d.Path, "remote_addr", d.RemoteAddr)
+	if err := a.audit.Record(r.Context(), d); err != nil {
+		auditLogger.ErrorContext(r.Context(), "failed to record access denial", "error", err)
+	}
+	http.Error(w, fmt.Sprintf("forbidden: requires the %s role", role), http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{RoleReader, RoleReader, true},
+		{RoleReader, RoleEditor, false},
+		{RoleReader, RoleAdmin, false},
+		{RoleEditor, RoleReader, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleAdmin, RoleReader, true},
+		{RoleAdmin, RoleEditor, true},
+		{RoleAdmin, RoleAdmin, true},
+		{Role(""), RoleReader, false},
+		{Role("root"), RoleReader, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Includes(tt.required); got != tt.want {
+			t.Errorf("%q.Includes(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestParseRoleRejectsUnknownRole(t *testing.T) {
+	if _, err := ParseRole("root"); err == nil {
+		t.Fatal("ParseRole accepted an unknown role")
+	}
+	if role, err := ParseRole("editor"); err != nil || role != RoleEditor {
+		t.Fatalf("ParseRole(editor) = %q, %v", role, err)
+	}
+}
+
+func requireStatus(a *Authorizer, required Role, id *Identity) int {
+	h := a.Require(required)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	r := httptest.NewRequest(http.MethodDelete, "/songs/1", nil)
+	if id != nil {
+		r = r.WithContext(WithIdentity(r.Context(), id))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRequireEnforcesRoles(t *testing.T) {
+	a := NewAuthorizer(NewMemoryAuditStore(), true)
+	for _, role := range Roles {
+		for _, required := range Roles {
+			want := http.StatusForbidden
+			if role.Includes(required) {
+				want = http.StatusNoContent
+			}
+			if got := requireStatus(a, required, &Identity{Subject: "c", Role: role}); got != want {
+				t.Errorf("role %s on %s route: status %d, want %d", role, required, got, want)
+			}
+		}
+	}
+}
+
+func TestRequireWithoutIdentityIsUnauthorized(t *testing.T) {
+	a := NewAuthorizer(NewMemoryAuditStore(), true)
+	if got := requireStatus(a, RoleReader, nil); got != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAllowsAllWhenDisabled(t *testing.T) {
+	a := NewAuthorizer(NewMemoryAuditStore(), false)
+	if got := requireStatus(a, RoleAdmin, nil); got != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", got, http.StatusNoContent)
+	}
+}
+
+func TestRequireRecordsDenials(t *testing.T) {
+	audit := NewMemoryAuditStore()
+	a := NewAuthorizer(audit, true)
+
+	requireStatus(a, RoleEditor, &Identity{Subject: "ok", Role: RoleAdmin})
+	requireStatus(a, RoleAdmin, &Identity{Subject: "partner-x", KeyID: 3, Role: RoleReader})
+
+	denials, err := audit.Recent(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(denials) != 1 {
+		t.Fatalf("recorded %d denials, want 1", len(denials))
+	}
+	d := denials[0]
+	if d.Subject != "partner-x" || d.KeyID != 3 || d.Role != RoleReader || d.RequiredRole != RoleAdmin ||
+		d.Method != http.MethodDelete || d.Path != "/songs/1" {
+		t.Fatalf("denial = %+v", d)
+	}
+}
+
+func TestMemoryAuditStoreRecentIsNewestFirst(t *testing.T) {
+	audit := NewMemoryAuditStore()
+	ctx := context.Background()
+	for _, subject := range []string{"a", "b", "c"} {
+		audit.Record(ctx, Denial{Subject: subject})
+	}
+
+	denials, _ := audit.Recent(ctx, 2)
+	if len(denials) != 2 || denials[0].Subject != "c" || denials[1].Subject != "b" {
+		t.Fatalf("Recent(2) = %+v, want c, b", denials)
+	}
+}
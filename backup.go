@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/backup"
+	"github.com/Kitrop/songGO-lib/config"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+// runBackup сохраняет все песни из базы данных в архив:
+//
+//	songgo backup [-o songs.backup.gz] [-tenant default]
+//
+// Вместо имени файла можно передать "-", чтобы писать в stdout. Файл
+// записывается во временный и переименовывается только после успешной выгрузки.
+// В архив попадает библиотека одного клиента.
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "songgo-"+time.Now().Format("20060102-150405")+".backup.gz", `archive path or "-" for stdout`)
+	tenantSlug := fs.String("tenant", tenant.DefaultSlug, "tenant whose library to back up")
+	cfg := loadConfig(fs, args)
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	schemaVersion, dirty, err := config.SchemaVersion(db)
+	if err != nil {
+		cliLogger.Error("failed to read the schema version", "error", err)
+		return 1
+	}
+	if dirty {
+		cliLogger.Error("database schema is dirty, backup not created", "schema_version", schemaVersion)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, err = tenantContext(ctx, db, *tenantSlug)
+	if err != nil {
+		cliLogger.Error("failed to resolve the tenant", "error", err)
+		return 1
+	}
+
+	repo := repository.NewPostgresRepository(db)
+	if *output == "-" {
+		trailer, err := backup.Write(ctx, os.Stdout, repo, schemaVersion)
+		if err != nil {
+			cliLogger.Error("backup failed", "error", err)
+			return 1
+		}
+		cliLogger.Info("backup written", "groups", trailer.Groups, "songs", trailer.Songs, "schema_version", schemaVersion)
+		return 0
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".songgo-backup-*")
+	if err != nil {
+		cliLogger.Error("failed to create the file", "error", err)
+		return 1
+	}
+	defer os.Remove(tmp.Name())
+
+	trailer, err := backup.Write(ctx, tmp, repo, schemaVersion)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), *output)
+	}
+	if err != nil {
+		cliLogger.Error("backup failed", "path", *output, "error", err)
+		return 1
+	}
+
+	fmt.Printf("%s: groups: %d, songs: %d, schema version: %d, sha256: %s\n", *output, trailer.Groups, trailer.Songs, schemaVersion, trailer.SHA256)
+	return 0
+}
+
+// runRestore заменяет содержимое хранилища песнями из архива:
+//
+//	songgo restore [-dry-run] [-backend postgres|memory] [-tenant default] file|-
+//
+// Заменяется только библиотека клиента -tenant. Архив полностью проверяется
+// до записи. С -dry-run только проверяет архив и выводит сводку, не
+// подключаясь к хранилищу. Хранилище memory живет только в процессе, поэтому
+// восстановление в него лишь проверяет, что архив загружается; для сервера
+// используйте memory_restore_path.
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "verify the archive and print a summary without writing anything")
+	backend := fs.String("backend", "", "target storage: postgres or memory (default: the configured storage)")
+	tenantSlug := fs.String("tenant", tenant.DefaultSlug, "tenant whose library to replace")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: songgo restore [flags] file|-")
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	archive, err := readArchive(fs.Arg(0))
+	if err != nil {
+		cliLogger.Error("invalid archive", "error", err)
+		return 1
+	}
+	fmt.Printf("archive: format version %d, created %s, schema version %d, groups: %d, songs: %d, sha256: %s\n",
+		archive.Header.Version, archive.Header.CreatedAt.Format(time.RFC3339),
+		archive.Header.SchemaVersion, len(archive.Groups), len(archive.Songs), archive.Trailer.SHA256)
+	if *dryRun {
+		fmt.Println("dry run: archive is valid, nothing was written")
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var repo repository.Repository
+	if *backend == "" {
+		*backend = cfg.StorageBackend
+	}
+	switch *backend {
+	case config.BackendPostgres:
+		db := openDB(cfg)
+		defer db.Close()
+
+		schemaVersion, _, err := config.SchemaVersion(db)
+		if err != nil {
+			cliLogger.Error("failed to read the schema version", "error", err)
+			return 1
+		}
+		if archive.Header.SchemaVersion > schemaVersion {
+			cliLogger.Error("archive was created on a newer schema",
+				"archive_schema_version", archive.Header.SchemaVersion, "schema_version", schemaVersion)
+			return 1
+		}
+		ctx, err = tenantContext(ctx, db, *tenantSlug)
+		if err != nil {
+			cliLogger.Error("failed to resolve the tenant", "error", err)
+			return 1
+		}
+		repo = repository.NewPostgresRepository(db)
+	case config.BackendMemory:
+		repo = repository.NewSongRepository()
+	default:
+		cliLogger.Error("unknown storage backend", "backend", *backend)
+		return 2
+	}
+
+	if err := backup.Restore(ctx, repo, archive); err != nil {
+		cliLogger.Error("restore failed", "error", err)
+		return 1
+	}
+	fmt.Printf("restored %d groups and %d songs\n", len(archive.Groups), len(archive.Songs))
+	return 0
+}
+
+// readArchive читает и проверяет архив из файла или stdin ("-").
+func readArchive(path string) (*backup.Archive, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть архив: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	return backup.Read(in)
+}
@@ -0,0 +1,289 @@
+// Package backup записывает и читает резервные копии библиотеки песен.
+//
+// Архив — это NDJSON, сжатый gzip. Первая строка содержит заголовок с версией
+// формата и версией схемы базы данных, за ней по одной строке на группу и на
+// песню, последняя строка — число групп и песен и SHA-256 их JSON-представлений.
+// Архив проверяется целиком до того, как что-либо будет записано в хранилище.
+//
+// В архивах версии 1 групп нет: при восстановлении песни находят группы по
+// названию.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+)
+
+const (
+	// Format — значение поля format в заголовке архива.
+	Format = "songgo-backup"
+	// FormatVersion — версия формата, которую записывает Write. Версия 2
+	// добавила группы.
+	FormatVersion = 2
+)
+
+// Максимальная длина строки архива (тексты песен бывают длинными).
+const maxLineSize = 4 << 20
+
+// ErrInvalidArchive возвращается, если архив поврежден или не прошел проверку.
+var ErrInvalidArchive = errors.New("invalid backup archive")
+
+// Header — первая строка архива.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// SchemaVersion — номер последней миграции golang-migrate в исходной базе.
+	SchemaVersion uint `json:"schemaVersion"`
+}
+
+// Trailer — последняя строка архива.
+type Trailer struct {
+	Groups int    `json:"groups,omitempty"`
+	Songs  int    `json:"songs"`
+	SHA256 string `json:"sha256"`
+}
+
+// entry — одна строка архива; заполнено ровно одно поле.
+type entry struct {
+	Header  *Header         `json:"header,omitempty"`
+	Group   json.RawMessage `json:"group,omitempty"`
+	Song    json.RawMessage `json:"song,omitempty"`
+	Trailer *Trailer        `json:"trailer,omitempty"`
+}
+
+// Archive — прочитанный и проверенный архив.
+type Archive struct {
+	Header  Header
+	Trailer Trailer
+	// Groups равна nil для архивов версии 1.
+	Groups []*repository.Group
+	Songs  []*database.Song
+}
+
+// Write выгружает все группы и песни из repo в w и возвращает итоговую строку архива.
+func Write(ctx context.Context, w io.Writer, repo repository.Repository, schemaVersion uint) (*Trailer, error) {
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+
+	header := Header{Format: Format, Version: FormatVersion, CreatedAt: time.Now().UTC(), SchemaVersion: schemaVersion}
+	if err := enc.Encode(entry{Header: &header}); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.New()
+	trailer := &Trailer{}
+	groups, err := repo.ListGroups(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		data, err := json.Marshal(group)
+		if err != nil {
+			return nil, err
+		}
+		writeChecksum(sum, data)
+		trailer.Groups++
+		if err := enc.Encode(entry{Group: data}); err != nil {
+			return nil, err
+		}
+	}
+
+	err = repo.StreamSongs(ctx, repository.SongFilter{}, func(song *database.Song) error {
+		data, err := json.Marshal(song)
+		if err != nil {
+			return err
+		}
+		writeChecksum(sum, data)
+		trailer.Songs++
+		return enc.Encode(entry{Song: data})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trailer.SHA256 = hex.EncodeToString(sum.Sum(nil))
+	if err := enc.Encode(entry{Trailer: trailer}); err != nil {
+		return nil, err
+	}
+	return trailer, gz.Close()
+}
+
+// Read читает архив и проверяет формат, контрольную сумму, число групп и
+// песен и корректность каждой записи: ID групп и песен, имена групп и пары
+// группа + название не повторяются, а песни ссылаются на группы из архива.
+func Read(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	archive := &Archive{}
+	sum := sha256.New()
+	ids := make(map[int32]bool)
+	names := make(map[[2]string]int32)
+	groups := make(map[int32]*repository.Group)
+	groupNames := make(map[string]int32)
+	line, done := 0, false
+	for scanner.Scan() {
+		line++
+		if done {
+			return nil, invalid(line, "unexpected data after the trailer")
+		}
+
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, invalid(line, err.Error())
+		}
+		switch {
+		case line == 1:
+			if e.Header == nil {
+				return nil, invalid(line, "missing header")
+			}
+			if e.Header.Format != Format {
+				return nil, invalid(line, fmt.Sprintf("unknown format %q", e.Header.Format))
+			}
+			if e.Header.Version < 1 || e.Header.Version > FormatVersion {
+				return nil, invalid(line, fmt.Sprintf("unsupported format version %d", e.Header.Version))
+			}
+			archive.Header = *e.Header
+			if archive.Header.Version >= 2 {
+				archive.Groups = []*repository.Group{}
+			}
+		case e.Group != nil:
+			if archive.Groups == nil {
+				return nil, invalid(line, "groups are not supported in format version 1")
+			}
+			if len(archive.Songs) > 0 {
+				return nil, invalid(line, "groups must precede songs")
+			}
+			group := &repository.Group{}
+			if err := json.Unmarshal(e.Group, group); err != nil {
+				return nil, invalid(line, err.Error())
+			}
+			if err := validateGroup(group, groups, groupNames); err != nil {
+				return nil, invalid(line, err.Error())
+			}
+			writeChecksum(sum, e.Group)
+			archive.Groups = append(archive.Groups, group)
+		case e.Song != nil:
+			song := &database.Song{}
+			if err := json.Unmarshal(e.Song, song); err != nil {
+				return nil, invalid(line, err.Error())
+			}
+			// Название группы в песне — копия имени группы, на которую она ссылается
+			if archive.Groups != nil {
+				group, ok := groups[song.GroupID]
+				if !ok {
+					return nil, invalid(line, fmt.Sprintf("song %d: unknown group ID %d", song.ID, song.GroupID))
+				}
+				song.GroupName = group.Name
+			}
+			if err := validateSong(song, ids, names); err != nil {
+				return nil, invalid(line, err.Error())
+			}
+			writeChecksum(sum, e.Song)
+			archive.Songs = append(archive.Songs, song)
+		case e.Trailer != nil:
+			archive.Trailer = *e.Trailer
+			done = true
+		default:
+			return nil, invalid(line, "unknown entry")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if !done {
+		return nil, fmt.Errorf("%w: archive is truncated, trailer not found", ErrInvalidArchive)
+	}
+
+	if archive.Trailer.Groups != len(archive.Groups) {
+		return nil, fmt.Errorf("%w: trailer lists %d groups, archive contains %d",
+			ErrInvalidArchive, archive.Trailer.Groups, len(archive.Groups))
+	}
+	if archive.Trailer.Songs != len(archive.Songs) {
+		return nil, fmt.Errorf("%w: trailer lists %d songs, archive contains %d",
+			ErrInvalidArchive, archive.Trailer.Songs, len(archive.Songs))
+	}
+	if checksum := hex.EncodeToString(sum.Sum(nil)); checksum != archive.Trailer.SHA256 {
+		return nil, fmt.Errorf("%w: checksum mismatch: expected %s, got %s",
+			ErrInvalidArchive, archive.Trailer.SHA256, checksum)
+	}
+	return archive, nil
+}
+
+// Restore заменяет содержимое repo группами и песнями из проверенного архива.
+func Restore(ctx context.Context, repo repository.Repository, archive *Archive) error {
+	return repo.RestoreSongs(ctx, archive.Groups, archive.Songs)
+}
+
+func validateGroup(group *repository.Group, ids map[int32]*repository.Group, names map[string]int32) error {
+	if group.ID <= 0 {
+		return fmt.Errorf("invalid group ID %d", group.ID)
+	}
+	if group.Name == "" {
+		return fmt.Errorf("group %d: name is required", group.ID)
+	}
+	if ids[group.ID] != nil {
+		return fmt.Errorf("duplicate group ID %d", group.ID)
+	}
+	key := repository.NormalizeName(group.Name)
+	if other, ok := names[key]; ok {
+		return fmt.Errorf("group %d duplicates group %d", group.ID, other)
+	}
+	if group.Aliases == nil {
+		group.Aliases = []string{}
+	}
+	ids[group.ID] = group
+	names[key] = group.ID
+	return nil
+}
+
+func validateSong(song *database.Song, ids map[int32]bool, names map[[2]string]int32) error {
+	if song.ID <= 0 {
+		return fmt.Errorf("invalid song ID %d", song.ID)
+	}
+	if song.GroupName == "" || song.Song == "" {
+		return fmt.Errorf("song %d: group and song are required", song.ID)
+	}
+	if song.Version < 1 {
+		return fmt.Errorf("song %d: invalid version %d", song.ID, song.Version)
+	}
+	if ids[song.ID] {
+		return fmt.Errorf("duplicate song ID %d", song.ID)
+	}
+	key := [2]string{repository.NormalizeName(song.GroupName), repository.NormalizeName(song.Song)}
+	if other, ok := names[key]; ok {
+		return fmt.Errorf("song %d duplicates song %d", song.ID, other)
+	}
+	ids[song.ID] = true
+	names[key] = song.ID
+	return nil
+}
+
+// writeChecksum добавляет JSON песни в контрольную сумму, отделяя песни переводом строки.
+func writeChecksum(sum hash.Hash, data []byte) {
+	sum.Write(data)
+	sum.Write([]byte{'\n'})
+}
+
+func invalid(line int, msg string) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidArchive, line, msg)
+}
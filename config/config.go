@@ -3,19 +3,22 @@ package config
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"io/fs"
+	"log/slog"
 
+	"github.com/Kitrop/songGO-lib/migrations"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
-func ConnectDB(ctx context.Context) (*sql.DB, error) {
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_PATH"))
+// ConnectDB открывает пул соединений с PostgreSQL и проверяет подключение.
+func ConnectDB(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -25,33 +28,86 @@ func ConnectDB(ctx context.Context) (*sql.DB, error) {
 	return db, nil
 }
 
-func LoadEnv() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+// LoadEnv загружает переменные из файла .env в текущем каталоге, если он есть.
+// Уже заданные переменные окружения не переопределяются.
+func LoadEnv() error {
+	err := godotenv.Load()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
 	}
+	if err != nil {
+		return fmt.Errorf("failed to load .env: %w", err)
+	}
+	return nil
 }
 
-// MigrateDB выполняет автоматические миграции.
-func MigrateDB(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// NewMigrator создает golang-migrate для миграций, встроенных в бинарный файл.
+// Мигратор занимает отдельное соединение из пула; Close освобождает его,
+// не закрывая сам пул.
+func NewMigrator(db *sql.DB) (*migrate.Migrate, error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("[ERROR] не удалось получить соединение с базой данных: %w", err)
+	}
+	driver, err := postgres.WithConnection(context.Background(), conn, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("[ERROR] не удалось создать драйвер базы данных: %w", err)
+		conn.Close()
+		return nil, fmt.Errorf("[ERROR] не удалось создать драйвер базы данных: %w", err)
 	}
 
-	migrator, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"song_go",
-		driver,
-	)
+	source, err := iofs.New(migrations.FS, ".")
 	if err != nil {
-		return fmt.Errorf("[ERROR] ошибка миграции: %w", err)
+		driver.Close()
+		return nil, fmt.Errorf("[ERROR] не удалось прочитать встроенные миграции: %w", err)
 	}
 
+	migrator, err := migrate.NewWithInstance("iofs", source, "song_go", driver)
+	if err != nil {
+		source.Close()
+		driver.Close()
+		return nil, fmt.Errorf("[ERROR] ошибка миграции: %w", err)
+	}
+	return migrator, nil
+}
+
+// MigrateDB выполняет автоматические миграции.
+func MigrateDB(db *sql.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
 	// Применяем миграции
 	if err := migrator.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("[ERROR] ошибка миграции: %w", err)
+		return fmt.Errorf("ошибка миграции: %w", err)
 	}
 
-	log.Println("[INFO] миграция прошла успешно")
+	slog.Info("database migrated")
 	return nil
-}
\ No newline at end of file
+}
+
+// SchemaVersion возвращает номер последней примененной миграции и признак
+// незавершенной (dirty) миграции. Для пустой базы возвращается 0.
+func SchemaVersion(db *sql.DB) (version uint, dirty bool, err error) {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return 0, false, err
+	}
+	defer migrator.Close()
+	version, dirty, err = migrator.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// CurrentSchemaVersion читает версию схемы прямо из таблицы golang-migrate,
+// не создавая мигратор. Подходит для частых проверок готовности.
+func CurrentSchemaVersion(ctx context.Context, db *sql.DB) (version uint, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
@@ -0,0 +1,852 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/Kitrop/songGO-lib/tenant"
+	"github.com/Kitrop/songGO-lib/tracing"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Хранилища песен.
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Config — настройки приложения. Источники в порядке возрастания приоритета:
+// значения по умолчанию, файл YAML или TOML, переменные окружения (и .env),
+// флаги командной строки.
+type Config struct {
+	// Port — адрес HTTP-сервера, например ":8080" или "127.0.0.1:8080".
+	Port string `yaml:"port" toml:"port"`
+	// DatabasePath — строка подключения к PostgreSQL.
+	DatabasePath string `yaml:"database_path" toml:"database_path"`
+	// ExternalAPIPath — адрес метода внешнего API с информацией о песне.
+	ExternalAPIPath string `yaml:"external_api_path" toml:"external_api_path"`
+	// ExternalAPITimeout — сколько ждать ответа внешнего API, включая чтение тела.
+	ExternalAPITimeout Duration `yaml:"external_api_timeout" toml:"external_api_timeout"`
+	// StorageBackend — хранилище песен: postgres или memory.
+	StorageBackend string `yaml:"storage_backend" toml:"storage_backend"`
+	// AutoMigrate — применять миграции при запуске.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+	// IdempotencyTTL — сколько хранится ответ на запрос с Idempotency-Key.
+	IdempotencyTTL Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl"`
+	// TrashRetention — сколько удаленная песня хранится в корзине до
+	// окончательного удаления.
+	TrashRetention Duration `yaml:"trash_retention" toml:"trash_retention"`
+	// MemoryRestorePath — резервная копия, загружаемая при старте хранилища memory.
+	MemoryRestorePath string `yaml:"memory_restore_path" toml:"memory_restore_path"`
+
+	// ReadTimeout — время на чтение запроса вместе с телом.
+	ReadTimeout Duration `yaml:"read_timeout" toml:"read_timeout"`
+	// WriteTimeout — время на обработку запроса и запись ответа. Импорт
+	// продлевает срок после каждой прочитанной порции тела, а выгрузка — после
+	// каждой записанной, так как они могут идти долго.
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	// IdleTimeout — сколько держать открытым простаивающее keep-alive соединение.
+	IdleTimeout Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// ShutdownTimeout — сколько ждать завершения запросов и фоновых задач при остановке.
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// ShutdownDelay — сколько после сигнала остановки сервер продолжает принимать
+	// запросы, отвечая на /readyz 503, чтобы балансировщик успел его исключить.
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	// MaxBodySize — максимальный размер тела запроса.
+	MaxBodySize ByteSize `yaml:"max_body_size" toml:"max_body_size"`
+	// MaxImportSize — максимальный размер тела запроса POST /import.
+	MaxImportSize ByteSize `yaml:"max_import_size" toml:"max_import_size"`
+
+	// LogLevel — уровень логирования по умолчанию: debug, info, warn или error.
+	LogLevel string `yaml:"log_level" toml:"log_level"`
+	// LogLevels — уровни отдельных компонентов, переопределяющие LogLevel,
+	// например {repository: debug, musicapi: warn}.
+	LogLevels StringMap `yaml:"log_levels" toml:"log_levels"`
+
+	// TracingExporter — куда отправлять спаны: none (отключено), stdout или otlp.
+	TracingExporter string `yaml:"tracing_exporter" toml:"tracing_exporter"`
+	// TracingEndpoint — адрес OTLP/HTTP коллектора, например http://localhost:4318.
+	// Если не задан, используются стандартные переменные OTEL_EXPORTER_OTLP_*.
+	TracingEndpoint string `yaml:"tracing_endpoint" toml:"tracing_endpoint"`
+	// TracingSampleRatio — доля записываемых трассировок, начатых сервисом (0..1).
+	TracingSampleRatio float64 `yaml:"tracing_sample_ratio" toml:"tracing_sample_ratio"`
+
+	// AuthEnabled — требовать API-ключ для всех маршрутов, кроме проверок
+	// состояния, метрик и Swagger.
+	AuthEnabled bool `yaml:"auth_enabled" toml:"auth_enabled"`
+	// APIKeys — ключи для хранилища memory: имя ключа и "[<клиент>:]<роль>:<SHA-256 в hex>"
+	// (выводится командой songgo apikey create -backend memory). Без роли ключ
+	// получает admin. С хранилищем postgres ключи хранятся в таблице api_keys.
+	APIKeys StringMap `yaml:"api_keys" toml:"api_keys"`
+
+	// JWTJWKSFile — файл JWKS с ключами проверки JWT от SSO. Пустое значение
+	// отключает JWT, принимаются только API-ключи.
+	JWTJWKSFile string `yaml:"jwt_jwks_file" toml:"jwt_jwks_file"`
+	// JWTIssuer — ожидаемый iss токена.
+	JWTIssuer string `yaml:"jwt_issuer" toml:"jwt_issuer"`
+	// JWTAudience — значение, которое должно быть в aud токена.
+	JWTAudience string `yaml:"jwt_audience" toml:"jwt_audience"`
+	// JWTRoleClaim — claim с ролями, вложенные поля через точку.
+	JWTRoleClaim string `yaml:"jwt_role_claim" toml:"jwt_role_claim"`
+	// JWTTenantClaim — claim с идентификатором клиента (slug).
+	JWTTenantClaim string `yaml:"jwt_tenant_claim" toml:"jwt_tenant_claim"`
+	// JWTRoleMap — роли SSO и соответствующие им роли сервиса.
+	JWTRoleMap StringMap `yaml:"jwt_role_map" toml:"jwt_role_map"`
+	// JWTLeeway — допустимое расхождение часов при проверке exp и nbf.
+	JWTLeeway Duration `yaml:"jwt_leeway" toml:"jwt_leeway"`
+
+	// RateLimitEnabled — ограничивать частоту запросов каждого клиента.
+	RateLimitEnabled bool `yaml:"rate_limit_enabled" toml:"rate_limit_enabled"`
+	// RateLimitRead — запросов на чтение (GET, HEAD) в секунду на клиента.
+	RateLimitRead float64 `yaml:"rate_limit_read" toml:"rate_limit_read"`
+	// RateLimitReadBurst — сколько запросов на чтение можно сделать подряд.
+	RateLimitReadBurst int `yaml:"rate_limit_read_burst" toml:"rate_limit_read_burst"`
+	// RateLimitWrite — изменяющих запросов в секунду на клиента.
+	RateLimitWrite float64 `yaml:"rate_limit_write" toml:"rate_limit_write"`
+	// RateLimitWriteBurst — сколько изменяющих запросов можно сделать подряд.
+	RateLimitWriteBurst int `yaml:"rate_limit_write_burst" toml:"rate_limit_write_burst"`
+	// RateLimitIP — запросов в секунду с одного IP-адреса, считая и запросы
+	// без действительных учетных данных.
+	RateLimitIP float64 `yaml:"rate_limit_ip" toml:"rate_limit_ip"`
+	// RateLimitIPBurst — сколько запросов с одного IP-адреса можно сделать подряд.
+	RateLimitIPBurst int `yaml:"rate_limit_ip_burst" toml:"rate_limit_ip_burst"`
+	// RateLimitStore — где хранить счетчики: memory (у каждого экземпляра
+	// свои) или postgres (общие для всех экземпляров).
+	RateLimitStore string `yaml:"rate_limit_store" toml:"rate_limit_store"`
+	// TrustedProxies — адреса и подсети (CIDR) балансировщиков, от которых
+	// принимается X-Forwarded-For. Пусто — адрес клиента берется из соединения.
+	TrustedProxies StringList `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// Default возвращает конфигурацию по умолчанию.
+func Default() Config {
+	return Config{
+		Port:               ":8080",
+		ExternalAPITimeout: Duration(10 * time.Second),
+		StorageBackend:     BackendPostgres,
+		AutoMigrate:        true,
+		IdempotencyTTL:     Duration(24 * time.Hour),
+		TrashRetention:     Duration(30 * 24 * time.Hour),
+
+		ReadTimeout:     Duration(15 * time.Second),
+		WriteTimeout:    Duration(30 * time.Second),
+		IdleTimeout:     Duration(2 * time.Minute),
+		ShutdownTimeout: Duration(30 * time.Second),
+		ShutdownDelay:   Duration(5 * time.Second),
+		MaxBodySize:     1 << 20,
+		MaxImportSize:   64 << 20,
+
+		LogLevel: "info",
+
+		TracingExporter:    tracing.ExporterNone,
+		TracingSampleRatio: 1,
+
+		AuthEnabled:    true,
+		JWTRoleClaim:   "roles",
+		JWTTenantClaim: "tenant",
+		JWTLeeway:      Duration(30 * time.Second),
+
+		RateLimitEnabled:    true,
+		RateLimitRead:       10,
+		RateLimitReadBurst:  50,
+		RateLimitWrite:      2,
+		RateLimitWriteBurst: 20,
+		RateLimitIP:         50,
+		RateLimitIPBurst:    200,
+		RateLimitStore:      BackendMemory,
+	}
+}
+
+// Logging возвращает уровень логирования по умолчанию и уровни компонентов.
+// Значения должны быть проверены Validate.
+func (c *Config) Logging() (slog.Level, map[string]slog.Level) {
+	level, _ := logging.ParseLevel(c.LogLevel)
+	components := make(map[string]slog.Level, len(c.LogLevels))
+	for component, v := range c.LogLevels {
+		components[component], _ = logging.ParseLevel(v)
+	}
+	return level, components
+}
+
+// TrustedProxyPrefixes возвращает TrustedProxies подсетями; отдельный адрес
+// становится подсетью из одного адреса. Значения должны быть проверены Validate.
+func (c *Config) TrustedProxyPrefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, v := range c.TrustedProxies {
+		if p, err := parseProxy(v); err == nil {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+func parseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Duration — time.Duration, которая в файлах конфигурации записывается строкой ("24h").
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// Set и String реализуют flag.Value.
+func (d *Duration) Set(s string) error { return d.UnmarshalText([]byte(s)) }
+
+// ByteSize — размер в байтах; записывается числом или с суффиксом KB, MB, GB
+// (степени 1024), например "10MB".
+type ByteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	s := strings.ToUpper(strings.TrimSpace(string(text)))
+	unit := int64(1)
+	for _, u := range byteSizeUnits {
+		if trimmed, ok := strings.CutSuffix(s, u.suffix); ok {
+			s, unit = strings.TrimSpace(trimmed), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, expected e.g. 512KB or 10MB", text)
+	}
+	*b = ByteSize(n * unit)
+	return nil
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b ByteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if b != 0 && int64(b)%u.size == 0 {
+			return strconv.FormatInt(int64(b)/u.size, 10) + u.suffix
+		}
+	}
+	return "0"
+}
+
+func (b *ByteSize) Set(s string) error { return b.UnmarshalText([]byte(s)) }
+
+// StringMap — словарь строк. В переменной окружения и флаге записывается
+// списком "repository=debug,musicapi=warn".
+type StringMap map[string]string
+
+func (m *StringMap) Set(s string) error {
+	values := StringMap{}
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid entry %q, expected key=value", part)
+		}
+		values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	*m = values
+	return nil
+}
+
+func (m StringMap) String() string {
+	parts := make([]string, 0, len(m))
+	for key, value := range m {
+		parts = append(parts, key+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// StringList — список строк. В переменной окружения и флаге записывается
+// через запятую: "10.0.0.0/8,192.168.1.10".
+type StringList []string
+
+func (l *StringList) Set(s string) error {
+	values := StringList{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	*l = values
+	return nil
+}
+
+func (l StringList) String() string {
+	return strings.Join(l, ",")
+}
+
+// Flags — флаги командной строки, переопределяющие конфигурацию.
+type Flags struct {
+	fs         *flag.FlagSet
+	configPath string
+	values     Config
+}
+
+// RegisterFlags добавляет в fs флаги конфигурации. Учитываются только флаги,
+// явно переданные в командной строке.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.StringVar(&f.configPath, "config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fs.StringVar(&f.values.Port, "port", "", "HTTP listen address, e.g. :8080 (env PORT)")
+	fs.StringVar(&f.values.DatabasePath, "database", "", "PostgreSQL connection string (env DATABASE_PATH)")
+	fs.StringVar(&f.values.ExternalAPIPath, "external-api", "", "external song info API URL (env EXTERNAL_API_PATH)")
+	fs.Var(&f.values.ExternalAPITimeout, "external-api-timeout", "time to wait for an external API response (env EXTERNAL_API_TIMEOUT)")
+	fs.StringVar(&f.values.StorageBackend, "storage", "", "song storage: postgres or memory (env STORAGE_BACKEND)")
+	fs.BoolVar(&f.values.AutoMigrate, "auto-migrate", true, "apply database migrations on start (env AUTO_MIGRATE)")
+	fs.Var(&f.values.IdempotencyTTL, "idempotency-ttl", "how long Idempotency-Key responses are kept (env IDEMPOTENCY_TTL)")
+	fs.Var(&f.values.TrashRetention, "trash-retention", "how long deleted songs stay in the trash before purging (env TRASH_RETENTION)")
+	fs.StringVar(&f.values.MemoryRestorePath, "memory-restore", "", "backup to load into the memory storage on start (env MEMORY_RESTORE_PATH)")
+	fs.Var(&f.values.ReadTimeout, "read-timeout", "time to read a request including the body (env READ_TIMEOUT)")
+	fs.Var(&f.values.WriteTimeout, "write-timeout", "time to handle a request and write the response (env WRITE_TIMEOUT)")
+	fs.Var(&f.values.IdleTimeout, "idle-timeout", "keep-alive connection idle timeout (env IDLE_TIMEOUT)")
+	fs.Var(&f.values.ShutdownTimeout, "shutdown-timeout", "time to drain requests and background jobs on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.Var(&f.values.ShutdownDelay, "shutdown-delay", "time to keep serving with /readyz failing before shutdown (env SHUTDOWN_DELAY)")
+	fs.Var(&f.values.MaxBodySize, "max-body-size", "maximum request body size, e.g. 1MB (env MAX_BODY_SIZE)")
+	fs.Var(&f.values.MaxImportSize, "max-import-size", "maximum POST /import body size (env MAX_IMPORT_SIZE)")
+	fs.StringVar(&f.values.LogLevel, "log-level", "", "default log level: debug, info, warn or error (env LOG_LEVEL)")
+	fs.StringVar(&f.values.TracingExporter, "tracing-exporter", "", "span exporter: none, stdout or otlp (env TRACING_EXPORTER)")
+	fs.StringVar(&f.values.TracingEndpoint, "tracing-endpoint", "", "OTLP/HTTP collector URL, e.g. http://localhost:4318 (env TRACING_ENDPOINT)")
+	fs.Float64Var(&f.values.TracingSampleRatio, "tracing-sample-ratio", 1, "fraction of traces started by the service to record (env TRACING_SAMPLE_RATIO)")
+	fs.BoolVar(&f.values.AuthEnabled, "auth", true, "require an API key (env AUTH_ENABLED)")
+	fs.StringVar(&f.values.JWTJWKSFile, "jwt-jwks-file", "", "JWKS file with JWT verification keys, enables JWT (env JWT_JWKS_FILE)")
+	fs.StringVar(&f.values.JWTIssuer, "jwt-issuer", "", "required JWT iss (env JWT_ISSUER)")
+	fs.StringVar(&f.values.JWTAudience, "jwt-audience", "", "required JWT aud (env JWT_AUDIENCE)")
+	fs.StringVar(&f.values.JWTRoleClaim, "jwt-role-claim", "", "JWT claim with roles, e.g. realm_access.roles (env JWT_ROLE_CLAIM)")
+	fs.StringVar(&f.values.JWTTenantClaim, "jwt-tenant-claim", "", "JWT claim with the tenant slug (env JWT_TENANT_CLAIM)")
+	fs.Var(&f.values.JWTRoleMap, "jwt-role-map", "SSO roles to service roles, e.g. songs-admins=admin (env JWT_ROLE_MAP)")
+	fs.Var(&f.values.JWTLeeway, "jwt-leeway", "allowed clock skew for JWT exp and nbf (env JWT_LEEWAY)")
+	fs.BoolVar(&f.values.RateLimitEnabled, "rate-limit", true, "limit the request rate per client (env RATE_LIMIT_ENABLED)")
+	fs.Float64Var(&f.values.RateLimitRead, "rate-limit-read", 0, "read requests per second per client (env RATE_LIMIT_READ)")
+	fs.IntVar(&f.values.RateLimitReadBurst, "rate-limit-read-burst", 0, "read requests a client can make at once (env RATE_LIMIT_READ_BURST)")
+	fs.Float64Var(&f.values.RateLimitWrite, "rate-limit-write", 0, "write requests per second per client (env RATE_LIMIT_WRITE)")
+	fs.IntVar(&f.values.RateLimitWriteBurst, "rate-limit-write-burst", 0, "write requests a client can make at once (env RATE_LIMIT_WRITE_BURST)")
+	fs.Float64Var(&f.values.RateLimitIP, "rate-limit-ip", 0, "requests per second per IP address, checked before authentication (env RATE_LIMIT_IP)")
+	fs.IntVar(&f.values.RateLimitIPBurst, "rate-limit-ip-burst", 0, "requests an IP address can make at once (env RATE_LIMIT_IP_BURST)")
+	fs.StringVar(&f.values.RateLimitStore, "rate-limit-store", "", "rate limit counters: memory or postgres (env RATE_LIMIT_STORE)")
+	fs.Var(&f.values.TrustedProxies, "trusted-proxies", "proxies allowed to set X-Forwarded-For, e.g. 10.0.0.0/8 (env TRUSTED_PROXIES)")
+	fs.Var(&f.values.LogLevels, "log-levels", "per-component log levels, e.g. repository=debug,musicapi=warn (env LOG_LEVELS)")
+	return f
+}
+
+func (f *Flags) apply(cfg *Config) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "port":
+			cfg.Port = f.values.Port
+		case "database":
+			cfg.DatabasePath = f.values.DatabasePath
+		case "external-api":
+			cfg.ExternalAPIPath = f.values.ExternalAPIPath
+		case "external-api-timeout":
+			cfg.ExternalAPITimeout = f.values.ExternalAPITimeout
+		case "storage":
+			cfg.StorageBackend = f.values.StorageBackend
+		case "auto-migrate":
+			cfg.AutoMigrate = f.values.AutoMigrate
+		case "idempotency-ttl":
+			cfg.IdempotencyTTL = f.values.IdempotencyTTL
+		case "trash-retention":
+			cfg.TrashRetention = f.values.TrashRetention
+		case "memory-restore":
+			cfg.MemoryRestorePath = f.values.MemoryRestorePath
+		case "read-timeout":
+			cfg.ReadTimeout = f.values.ReadTimeout
+		case "write-timeout":
+			cfg.WriteTimeout = f.values.WriteTimeout
+		case "idle-timeout":
+			cfg.IdleTimeout = f.values.IdleTimeout
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = f.values.ShutdownTimeout
+		case "shutdown-delay":
+			cfg.ShutdownDelay = f.values.ShutdownDelay
+		case "max-body-size":
+			cfg.MaxBodySize = f.values.MaxBodySize
+		case "max-import-size":
+			cfg.MaxImportSize = f.values.MaxImportSize
+		case "log-level":
+			cfg.LogLevel = f.values.LogLevel
+		case "log-levels":
+			cfg.LogLevels = f.values.LogLevels
+		case "auth":
+			cfg.AuthEnabled = f.values.AuthEnabled
+		case "jwt-jwks-file":
+			cfg.JWTJWKSFile = f.values.JWTJWKSFile
+		case "jwt-issuer":
+			cfg.JWTIssuer = f.values.JWTIssuer
+		case "jwt-audience":
+			cfg.JWTAudience = f.values.JWTAudience
+		case "jwt-role-claim":
+			cfg.JWTRoleClaim = f.values.JWTRoleClaim
+		case "jwt-tenant-claim":
+			cfg.JWTTenantClaim = f.values.JWTTenantClaim
+		case "jwt-role-map":
+			cfg.JWTRoleMap = f.values.JWTRoleMap
+		case "jwt-leeway":
+			cfg.JWTLeeway = f.values.JWTLeeway
+		case "rate-limit":
+			cfg.RateLimitEnabled = f.values.RateLimitEnabled
+		case "rate-limit-read":
+			cfg.RateLimitRead = f.values.RateLimitRead
+		case "rate-limit-read-burst":
+			cfg.RateLimitReadBurst = f.values.RateLimitReadBurst
+		case "rate-limit-write":
+			cfg.RateLimitWrite = f.values.RateLimitWrite
+		case "rate-limit-write-burst":
+			cfg.RateLimitWriteBurst = f.values.RateLimitWriteBurst
+		case "rate-limit-ip":
+			cfg.RateLimitIP = f.values.RateLimitIP
+		case "rate-limit-ip-burst":
+			cfg.RateLimitIPBurst = f.values.RateLimitIPBurst
+		case "rate-limit-store":
+			cfg.RateLimitStore = f.values.RateLimitStore
+		case "trusted-proxies":
+			cfg.TrustedProxies = f.values.TrustedProxies
+		case "tracing-exporter":
+			cfg.TracingExporter = f.values.TracingExporter
+		case "tracing-endpoint":
+			cfg.TracingEndpoint = f.values.TracingEndpoint
+		case "tracing-sample-ratio":
+			cfg.TracingSampleRatio = f.values.TracingSampleRatio
+		}
+	})
+}
+
+// Load собирает и проверяет конфигурацию. flags могут быть nil; тогда
+// учитываются только файл из CONFIG_FILE и переменные окружения.
+func Load(flags *Flags) (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if flags != nil && flags.configPath != "" {
+		path = flags.configPath
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if flags != nil {
+		flags.apply(&cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile читает YAML (.yaml, .yml) или TOML (.toml). Неизвестные ключи — ошибка,
+// чтобы опечатка в имени параметра не проходила незамеченной.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %q", undecoded[0].String())
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported config file type %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+}
+
+func applyEnv(cfg *Config) error {
+	if v, ok := os.LookupEnv("PORT"); ok {
+		cfg.Port = v
+	}
+	if v, ok := os.LookupEnv("DATABASE_PATH"); ok {
+		cfg.DatabasePath = v
+	}
+	if v, ok := os.LookupEnv("EXTERNAL_API_PATH"); ok {
+		cfg.ExternalAPIPath = v
+	}
+	if v, ok := os.LookupEnv("STORAGE_BACKEND"); ok && v != "" {
+		cfg.StorageBackend = v
+	}
+	if v, ok := os.LookupEnv("MEMORY_RESTORE_PATH"); ok {
+		cfg.MemoryRestorePath = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = v
+	}
+	if v, ok := os.LookupEnv("LOG_LEVELS"); ok {
+		if err := cfg.LogLevels.Set(v); err != nil {
+			return fmt.Errorf("LOG_LEVELS: %v", err)
+		}
+	}
+	if v := os.Getenv("TRACING_EXPORTER"); v != "" {
+		cfg.TracingExporter = v
+	}
+	if v, ok := os.LookupEnv("TRACING_ENDPOINT"); ok {
+		cfg.TracingEndpoint = v
+	}
+	if v := os.Getenv("TRACING_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("TRACING_SAMPLE_RATIO: invalid number %q", v)
+		}
+		cfg.TracingSampleRatio = ratio
+	}
+	if v := os.Getenv("AUTH_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("AUTH_ENABLED: invalid boolean %q", v)
+		}
+		cfg.AuthEnabled = enabled
+	}
+	if v, ok := os.LookupEnv("API_KEYS"); ok {
+		if err := cfg.APIKeys.Set(v); err != nil {
+			return fmt.Errorf("API_KEYS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("JWT_JWKS_FILE"); ok {
+		cfg.JWTJWKSFile = v
+	}
+	if v, ok := os.LookupEnv("JWT_ISSUER"); ok {
+		cfg.JWTIssuer = v
+	}
+	if v, ok := os.LookupEnv("JWT_AUDIENCE"); ok {
+		cfg.JWTAudience = v
+	}
+	if v := os.Getenv("JWT_ROLE_CLAIM"); v != "" {
+		cfg.JWTRoleClaim = v
+	}
+	if v := os.Getenv("JWT_TENANT_CLAIM"); v != "" {
+		cfg.JWTTenantClaim = v
+	}
+	if v, ok := os.LookupEnv("JWT_ROLE_MAP"); ok {
+		if err := cfg.JWTRoleMap.Set(v); err != nil {
+			return fmt.Errorf("JWT_ROLE_MAP: %v", err)
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMIT_ENABLED: invalid boolean %q", v)
+		}
+		cfg.RateLimitEnabled = enabled
+	}
+	rates := []struct {
+		env   string
+		value *float64
+	}{
+		{"RATE_LIMIT_READ", &cfg.RateLimitRead},
+		{"RATE_LIMIT_WRITE", &cfg.RateLimitWrite},
+		{"RATE_LIMIT_IP", &cfg.RateLimitIP},
+	}
+	for _, rate := range rates {
+		if v := os.Getenv(rate.env); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", rate.env, v)
+			}
+			*rate.value = n
+		}
+	}
+	bursts := []struct {
+		env   string
+		value *int
+	}{
+		{"RATE_LIMIT_READ_BURST", &cfg.RateLimitReadBurst},
+		{"RATE_LIMIT_WRITE_BURST", &cfg.RateLimitWriteBurst},
+		{"RATE_LIMIT_IP_BURST", &cfg.RateLimitIPBurst},
+	}
+	for _, burst := range bursts {
+		if v := os.Getenv(burst.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", burst.env, v)
+			}
+			*burst.value = n
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_STORE"); v != "" {
+		cfg.RateLimitStore = v
+	}
+	if v, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.TrustedProxies.Set(v)
+	}
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		autoMigrate, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("AUTO_MIGRATE: invalid boolean %q", v)
+		}
+		cfg.AutoMigrate = autoMigrate
+	}
+	durations := []struct {
+		env   string
+		value *Duration
+	}{
+		{"EXTERNAL_API_TIMEOUT", &cfg.ExternalAPITimeout},
+		{"IDEMPOTENCY_TTL", &cfg.IdempotencyTTL},
+		{"TRASH_RETENTION", &cfg.TrashRetention},
+		{"READ_TIMEOUT", &cfg.ReadTimeout},
+		{"WRITE_TIMEOUT", &cfg.WriteTimeout},
+		{"IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout},
+		{"SHUTDOWN_DELAY", &cfg.ShutdownDelay},
+		{"JWT_LEEWAY", &cfg.JWTLeeway},
+	}
+	for _, d := range durations {
+		if v := os.Getenv(d.env); v != "" {
+			if err := d.value.Set(v); err != nil {
+				return fmt.Errorf("%s: invalid duration %q, expected e.g. 24h or 30s", d.env, v)
+			}
+		}
+	}
+	sizes := []struct {
+		env   string
+		value *ByteSize
+	}{
+		{"MAX_BODY_SIZE", &cfg.MaxBodySize},
+		{"MAX_IMPORT_SIZE", &cfg.MaxImportSize},
+	}
+	for _, sz := range sizes {
+		if v := os.Getenv(sz.env); v != "" {
+			if err := sz.value.Set(v); err != nil {
+				return fmt.Errorf("%s: %v", sz.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	// Для совместимости принимаем и голый номер порта
+	if _, err := strconv.Atoi(c.Port); err == nil {
+		c.Port = ":" + c.Port
+	}
+	if _, port, err := net.SplitHostPort(c.Port); err != nil {
+		add("port: invalid listen address %q, expected host:port or :port", c.Port)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		add("port: invalid port %q", port)
+	}
+
+	switch c.StorageBackend {
+	case BackendPostgres:
+		if c.DatabasePath == "" {
+			add("database_path: required when storage_backend is %s", BackendPostgres)
+		}
+	case BackendMemory:
+	default:
+		add("storage_backend: unknown value %q, expected %s or %s", c.StorageBackend, BackendPostgres, BackendMemory)
+	}
+
+	if c.ExternalAPIPath != "" {
+		u, err := url.Parse(c.ExternalAPIPath)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("external_api_path: invalid URL %q, expected http(s)://host/path", c.ExternalAPIPath)
+		}
+	}
+
+	positive := []struct {
+		name  string
+		value Duration
+	}{
+		{"external_api_timeout", c.ExternalAPITimeout},
+		{"idempotency_ttl", c.IdempotencyTTL},
+		{"trash_retention", c.TrashRetention},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+	}
+	for _, d := range positive {
+		if d.value <= 0 {
+			add("%s: must be positive, got %s", d.name, d.value)
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		add("shutdown_delay: must not be negative, got %s", c.ShutdownDelay)
+	}
+	if c.MaxBodySize <= 0 {
+		add("max_body_size: must be positive")
+	}
+	if c.MaxImportSize < c.MaxBodySize {
+		add("max_import_size: must not be smaller than max_body_size (%s)", c.MaxBodySize)
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		add("log_level: %v", err)
+	}
+	for _, component := range sortedKeys(c.LogLevels) {
+		if !slices.Contains(logging.Components, component) {
+			add("log_levels: unknown component %q, expected one of %s", component, strings.Join(logging.Components, ", "))
+		} else if _, err := logging.ParseLevel(c.LogLevels[component]); err != nil {
+			add("log_levels.%s: %v", component, err)
+		}
+	}
+
+	for _, name := range sortedKeys(c.APIKeys) {
+		key, err := auth.ParseStaticKey(c.APIKeys[name])
+		if err != nil {
+			add("api_keys.%s: %v", name, err)
+		} else if key.Tenant != "" && !tenant.ValidSlug(key.Tenant) {
+			add("api_keys.%s: invalid tenant %q, expected lowercase letters, digits and dashes", name, key.Tenant)
+		}
+	}
+	if len(c.APIKeys) > 0 && c.StorageBackend != BackendMemory {
+		add("api_keys: only used with storage_backend %s, create keys with songgo apikey create", BackendMemory)
+	}
+
+	if c.JWTJWKSFile != "" {
+		if c.JWTIssuer == "" {
+			add("jwt_issuer: required when jwt_jwks_file is set")
+		}
+		if c.JWTAudience == "" {
+			add("jwt_audience: required when jwt_jwks_file is set")
+		}
+	}
+	if c.JWTRoleClaim == "" {
+		add("jwt_role_claim: must not be empty")
+	}
+	if c.JWTTenantClaim == "" {
+		add("jwt_tenant_claim: must not be empty")
+	}
+	for _, name := range sortedKeys(c.JWTRoleMap) {
+		if _, err := auth.ParseRole(c.JWTRoleMap[name]); err != nil {
+			add("jwt_role_map.%s: %v", name, err)
+		}
+	}
+	if c.JWTLeeway < 0 {
+		add("jwt_leeway: must not be negative, got %s", c.JWTLeeway)
+	}
+
+	if c.RateLimitRead <= 0 {
+		add("rate_limit_read: must be positive, got %g", c.RateLimitRead)
+	}
+	if c.RateLimitWrite <= 0 {
+		add("rate_limit_write: must be positive, got %g", c.RateLimitWrite)
+	}
+	if c.RateLimitReadBurst < 1 {
+		add("rate_limit_read_burst: must be at least 1, got %d", c.RateLimitReadBurst)
+	}
+	if c.RateLimitWriteBurst < 1 {
+		add("rate_limit_write_burst: must be at least 1, got %d", c.RateLimitWriteBurst)
+	}
+	if c.RateLimitIP <= 0 {
+		add("rate_limit_ip: must be positive, got %g", c.RateLimitIP)
+	}
+	if c.RateLimitIPBurst < 1 {
+		add("rate_limit_ip_burst: must be at least 1, got %d", c.RateLimitIPBurst)
+	}
+	switch c.RateLimitStore {
+	case BackendMemory:
+	case BackendPostgres:
+		if c.StorageBackend != BackendPostgres {
+			add("rate_limit_store: %s requires storage_backend %s", BackendPostgres, BackendPostgres)
+		}
+	default:
+		add("rate_limit_store: unknown value %q, expected %s or %s", c.RateLimitStore, BackendMemory, BackendPostgres)
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			add("trusted_proxies: invalid address or CIDR %q", proxy)
+		}
+	}
+
+	switch c.TracingExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		add("tracing_exporter: unknown value %q, expected %s, %s or %s",
+			c.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)
+	}
+	if c.TracingEndpoint != "" {
+		u, err := url.Parse(c.TracingEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("tracing_endpoint: invalid URL %q, expected http(s)://host:port", c.TracingEndpoint)
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		add("tracing_sample_ratio: must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(msgs, "\n  "))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,218 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: api_keys.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createAPIKey = `-- name: CreateAPIKey :one
+INSERT INTO api_keys (name, prefix, key_hash, role, tenant_id)
+SELECT $1, $2, $3, $4, tenants.id
+FROM (SELECT $5::text AS slug) AS requested
+LEFT JOIN tenants ON tenants.slug = requested.slug
+WHERE requested.slug IS NULL OR tenants.id IS NOT NULL
+RETURNING id, name, prefix, key_hash, created_at, last_used_at, revoked_at, role, tenant_id
+`
+
+type CreateAPIKeyParams struct {
+	Name    string
+	Prefix  string
+	KeyHash string
+	Role    string
+	Tenant  sql.NullString
+}
+
+// Если клиента с указанным slug нет, ключ не создается и запрос не возвращает строк.
+func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, createAPIKey,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.Role,
+		arg.Tenant,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+		&i.Role,
+		&i.TenantID,
+	)
+	return i, err
+}
+
+const getActiveAPIKeyByHash = `-- name: GetActiveAPIKeyByHash :one
+SELECT api_keys.id, api_keys.name, api_keys.prefix, api_keys.key_hash, api_keys.created_at, api_keys.last_used_at, api_keys.revoked_at, api_keys.role, api_keys.tenant_id, tenants.slug AS tenant
+FROM api_keys LEFT JOIN tenants ON tenants.id = api_keys.tenant_id
+WHERE api_keys.key_hash = $1 AND api_keys.revoked_at IS NULL
+`
+
+type GetActiveAPIKeyByHashRow struct {
+	ApiKey ApiKey
+	Tenant sql.NullString
+}
+
+func (q *Queries) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (GetActiveAPIKeyByHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getActiveAPIKeyByHash, keyHash)
+	var i GetActiveAPIKeyByHashRow
+	err := row.Scan(
+		&i.ApiKey.ID,
+		&i.ApiKey.Name,
+		&i.ApiKey.Prefix,
+		&i.ApiKey.KeyHash,
+		&i.ApiKey.CreatedAt,
+		&i.ApiKey.LastUsedAt,
+		&i.ApiKey.RevokedAt,
+		&i.ApiKey.Role,
+		&i.ApiKey.TenantID,
+		&i.Tenant,
+	)
+	return i, err
+}
+
+const insertAccessDenial = `-- name: InsertAccessDenial :exec
+INSERT INTO access_denials (subject, key_id, role, required_role, method, path, remote_addr, request_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+`
+
+type InsertAccessDenialParams struct {
+	Subject      string
+	KeyID        sql.NullInt32
+	Role         string
+	RequiredRole string
+	Method       string
+	Path         string
+	RemoteAddr   string
+	RequestID    string
+}
+
+func (q *Queries) InsertAccessDenial(ctx context.Context, arg InsertAccessDenialParams) error {
+	_, err := q.db.ExecContext(ctx, insertAccessDenial,
+		arg.Subject,
+		arg.KeyID,
+		arg.Role,
+		arg.RequiredRole,
+		arg.Method,
+		arg.Path,
+		arg.RemoteAddr,
+		arg.RequestID,
+	)
+	return err
+}
+
+const listAPIKeys = `-- name: ListAPIKeys :many
+SELECT api_keys.id, api_keys.name, api_keys.prefix, api_keys.key_hash, api_keys.created_at, api_keys.last_used_at, api_keys.revoked_at, api_keys.role, api_keys.tenant_id, tenants.slug AS tenant
+FROM api_keys LEFT JOIN tenants ON tenants.id = api_keys.tenant_id
+ORDER BY api_keys.id
+`
+
+type ListAPIKeysRow struct {
+	ApiKey ApiKey
+	Tenant sql.NullString
+}
+
+func (q *Queries) ListAPIKeys(ctx context.Context) ([]ListAPIKeysRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAPIKeysRow
+	for rows.Next() {
+		var i ListAPIKeysRow
+		if err := rows.Scan(
+			&i.ApiKey.ID,
+			&i.ApiKey.Name,
+			&i.ApiKey.Prefix,
+			&i.ApiKey.KeyHash,
+			&i.ApiKey.CreatedAt,
+			&i.ApiKey.LastUsedAt,
+			&i.ApiKey.RevokedAt,
+			&i.ApiKey.Role,
+			&i.ApiKey.TenantID,
+			&i.Tenant,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccessDenials = `-- name: ListAccessDenials :many
+SELECT id, created_at, subject, key_id, role, required_role, method, path, remote_addr, request_id FROM access_denials ORDER BY id DESC LIMIT $1
+`
+
+func (q *Queries) ListAccessDenials(ctx context.Context, limit int32) ([]AccessDenial, error) {
+	rows, err := q.db.QueryContext(ctx, listAccessDenials, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AccessDenial
+	for rows.Next() {
+		var i AccessDenial
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Subject,
+			&i.KeyID,
+			&i.Role,
+			&i.RequiredRole,
+			&i.Method,
+			&i.Path,
+			&i.RemoteAddr,
+			&i.RequestID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :execrows
+UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIKey, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys SET last_used_at = now()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+`
+
+// Время последнего использования обновляется не чаще раза в минуту,
+// чтобы не писать в базу на каждый запрос.
+func (q *Queries) TouchAPIKey(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, touchAPIKey, id)
+	return err
+}
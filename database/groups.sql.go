@@ -0,0 +1,293 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: groups.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createGroup = `-- name: CreateGroup :one
+INSERT INTO groups (tenant_id, name, country, formed_year, aliases)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, tenant_id, name, country, formed_year, aliases, created_at
+`
+
+type CreateGroupParams struct {
+	TenantID   int32
+	Name       string
+	Country    sql.NullString
+	FormedYear sql.NullInt32
+	Aliases    []string
+}
+
+func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, createGroup,
+		arg.TenantID,
+		arg.Name,
+		arg.Country,
+		arg.FormedYear,
+		pq.Array(arg.Aliases),
+	)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Country,
+		&i.FormedYear,
+		pq.Array(&i.Aliases),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteAllGroups = `-- name: DeleteAllGroups :exec
+DELETE FROM groups WHERE tenant_id = $1
+`
+
+func (q *Queries) DeleteAllGroups(ctx context.Context, tenantID int32) error {
+	_, err := q.db.ExecContext(ctx, deleteAllGroups, tenantID)
+	return err
+}
+
+const deleteGroup = `-- name: DeleteGroup :execrows
+DELETE FROM groups WHERE id = $1 AND tenant_id = $2
+`
+
+type DeleteGroupParams struct {
+	ID       int32
+	TenantID int32
+}
+
+func (q *Queries) DeleteGroup(ctx context.Context, arg DeleteGroupParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGroup, arg.ID, arg.TenantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getGroup = `-- name: GetGroup :one
+SELECT id, tenant_id, name, country, formed_year, aliases, created_at FROM groups WHERE id = $1 AND tenant_id = $2
+`
+
+type GetGroupParams struct {
+	ID       int32
+	TenantID int32
+}
+
+func (q *Queries) GetGroup(ctx context.Context, arg GetGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, getGroup, arg.ID, arg.TenantID)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Country,
+		&i.FormedYear,
+		pq.Array(&i.Aliases),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listGroups = `-- name: ListGroups :many
+SELECT id, tenant_id, name, country, formed_year, aliases, created_at FROM groups
+WHERE tenant_id = $1
+  AND ($2::text = ''
+    OR strpos(normalize_name(name), normalize_name($2::text)) > 0
+    OR EXISTS (SELECT 1 FROM unnest(aliases) AS alias WHERE strpos(normalize_name(alias), normalize_name($2::text)) > 0))
+ORDER BY normalize_name(name), id
+`
+
+type ListGroupsParams struct {
+	TenantID int32
+	Query    string
+}
+
+// Поиск по подстроке имени или псевдонима без учета регистра и пробелов
+func (q *Queries) ListGroups(ctx context.Context, arg ListGroupsParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroups, arg.TenantID, arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.Name,
+			&i.Country,
+			&i.FormedYear,
+			pq.Array(&i.Aliases),
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockGroup = `-- name: LockGroup :one
+SELECT id, tenant_id, name, country, formed_year, aliases, created_at FROM groups WHERE id = $1 AND tenant_id = $2 FOR SHARE
+`
+
+type LockGroupParams struct {
+	ID       int32
+	TenantID int32
+}
+
+// Блокирует группу до конца транзакции, чтобы ее не переименовали и не
+// удалили, пока к ней добавляется песня
+func (q *Queries) LockGroup(ctx context.Context, arg LockGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, lockGroup, arg.ID, arg.TenantID)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Country,
+		&i.FormedYear,
+		pq.Array(&i.Aliases),
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const renameGroupSongs = `-- name: RenameGroupSongs :many
+UPDATE songs SET group_name = $1,
+    version = CASE WHEN deleted_at IS NULL THEN version + 1 ELSE version END
+WHERE group_id = $2
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
+`
+
+type RenameGroupSongsParams struct {
+	Name    string
+	GroupID int32
+}
+
+// Версия меняется только у песен вне корзины: у них записывается ревизия
+func (q *Queries) RenameGroupSongs(ctx context.Context, arg RenameGroupSongsParams) ([]Song, error) {
+	rows, err := q.db.QueryContext(ctx, renameGroupSongs, arg.Name, arg.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Song
+	for rows.Next() {
+		var i Song
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupName,
+			&i.Song,
+			&i.ReleaseDate,
+			&i.SongText,
+			&i.Link,
+			&i.ReleaseDatePrecision,
+			&i.Version,
+			&i.TenantID,
+			&i.DeletedAt,
+			&i.GroupID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resetGroupsIDSequence = `-- name: ResetGroupsIDSequence :exec
+SELECT setval(pg_get_serial_sequence('groups', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM groups
+`
+
+func (q *Queries) ResetGroupsIDSequence(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, resetGroupsIDSequence)
+	return err
+}
+
+const restoreGroup = `-- name: RestoreGroup :exec
+INSERT INTO groups (id, tenant_id, name, country, formed_year, aliases, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+`
+
+type RestoreGroupParams struct {
+	ID         int32
+	TenantID   int32
+	Name       string
+	Country    sql.NullString
+	FormedYear sql.NullInt32
+	Aliases    []string
+	CreatedAt  time.Time
+}
+
+func (q *Queries) RestoreGroup(ctx context.Context, arg RestoreGroupParams) error {
+	_, err := q.db.ExecContext(ctx, restoreGroup,
+		arg.ID,
+		arg.TenantID,
+		arg.Name,
+		arg.Country,
+		arg.FormedYear,
+		pq.Array(arg.Aliases),
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const updateGroup = `-- name: UpdateGroup :one
+UPDATE groups SET name = $3, country = $4, formed_year = $5, aliases = $6
+WHERE id = $1 AND tenant_id = $2
+RETURNING id, tenant_id, name, country, formed_year, aliases, created_at
+`
+
+type UpdateGroupParams struct {
+	ID         int32
+	TenantID   int32
+	Name       string
+	Country    sql.NullString
+	FormedYear sql.NullInt32
+	Aliases    []string
+}
+
+func (q *Queries) UpdateGroup(ctx context.Context, arg UpdateGroupParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, updateGroup,
+		arg.ID,
+		arg.TenantID,
+		arg.Name,
+		arg.Country,
+		arg.FormedYear,
+		pq.Array(arg.Aliases),
+	)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.TenantID,
+		&i.Name,
+		&i.Country,
+		&i.FormedYear,
+		pq.Array(&i.Aliases),
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -6,13 +6,145 @@ package database
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
 )
 
+type DatePrecision string
+
+const (
+	DatePrecisionDay   DatePrecision = "day"
+	DatePrecisionMonth DatePrecision = "month"
+	DatePrecisionYear  DatePrecision = "year"
+)
+
+func (e *DatePrecision) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = DatePrecision(s)
+	case string:
+		*e = DatePrecision(s)
+	default:
+		return fmt.Errorf("unsupported scan type for DatePrecision: %T", src)
+	}
+	return nil
+}
+
+type NullDatePrecision struct {
+	DatePrecision DatePrecision
+	Valid         bool // Valid is true if DatePrecision is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullDatePrecision) Scan(value interface{}) error {
+	if value == nil {
+		ns.DatePrecision, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.DatePrecision.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullDatePrecision) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.DatePrecision), nil
+}
+
+type AccessDenial struct {
+	ID           int64
+	CreatedAt    time.Time
+	Subject      string
+	KeyID        sql.NullInt32
+	Role         string
+	RequiredRole string
+	Method       string
+	Path         string
+	RemoteAddr   string
+	RequestID    string
+}
+
+type ApiKey struct {
+	ID         int32
+	Name       string
+	Prefix     string
+	KeyHash    string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+	Role       string
+	TenantID   sql.NullInt32
+}
+
+type Group struct {
+	ID         int32
+	TenantID   int32
+	Name       string
+	Country    sql.NullString
+	FormedYear sql.NullInt32
+	Aliases    []string
+	CreatedAt  time.Time
+}
+
+type IdempotencyKey struct {
+	Key             string
+	RequestHash     string
+	StatusCode      sql.NullInt32
+	ResponseHeaders json.RawMessage
+	ResponseBody    []byte
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	Allowed   bool
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
 type Song struct {
-	ID          int32          `json:"id" example:"1"`
-	GroupName   string         `json:"groupName" example:"Muse"`
-	Song        string         `json:"song" example:"Supermassive Black Hole"`
-	ReleaseDate sql.NullString `json:"releaseDate" swaggertype:"string" example:"16.07.2006"`
-	SongText    sql.NullString `json:"songText,omitempty" swaggertype:"string" example:"Ooh baby, don't you know I suffer?..."`
-	Link        sql.NullString `json:"link,omitempty" swaggertype:"string" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+	ID                   int32          `json:"id" example:"1"`
+	GroupName            string         `json:"groupName" example:"Muse"`
+	Song                 string         `json:"song" example:"Supermassive Black Hole"`
+	ReleaseDate          sql.NullTime   `json:"releaseDate" swaggertype:"string" example:"2006-07-16"`
+	SongText             sql.NullString `json:"songText,omitempty" swaggertype:"string" example:"Ooh baby, don't you know I suffer?..."`
+	Link                 sql.NullString `json:"link,omitempty" swaggertype:"string" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+	ReleaseDatePrecision DatePrecision  `json:"-"`
+	Version              int32          `json:"version" example:"1"`
+	TenantID             int32          `json:"-"`
+	DeletedAt            sql.NullTime   `json:"-"`
+	GroupID              int32          `json:"groupId" example:"1"`
+}
+
+type SongRevision struct {
+	ID                   int64
+	TenantID             int32
+	SongID               int32
+	Revision             int32
+	Action               string
+	Actor                sql.NullString
+	RevertedFrom         sql.NullInt32
+	CreatedAt            time.Time
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	Version              int32
+	GroupID              sql.NullInt32
+}
+
+type Tenant struct {
+	ID        int32
+	Slug      string
+	Name      string
+	MaxSongs  sql.NullInt32
+	CreatedAt time.Time
 }
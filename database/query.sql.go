@@ -10,18 +10,31 @@ import (
 	"database/sql"
 )
 
+const countSongs = `-- name: CountSongs :one
+SELECT count(*) FROM songs WHERE tenant_id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) CountSongs(ctx context.Context, tenantID int32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSongs, tenantID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createSong = `-- name: CreateSong :one
-INSERT INTO songs (group_name, song, release_date, song_text, link)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, group_name, song, release_date, song_text, link
+INSERT INTO songs (group_name, song, release_date, release_date_precision, song_text, link, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
 `
 
 type CreateSongParams struct {
-	GroupName   string
-	Song        string
-	ReleaseDate sql.NullString
-	SongText    sql.NullString
-	Link        sql.NullString
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	TenantID             int32
 }
 
 func (q *Queries) CreateSong(ctx context.Context, arg CreateSongParams) (Song, error) {
@@ -29,8 +42,10 @@ func (q *Queries) CreateSong(ctx context.Context, arg CreateSongParams) (Song, e
 		arg.GroupName,
 		arg.Song,
 		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
 		arg.SongText,
 		arg.Link,
+		arg.TenantID,
 	)
 	var i Song
 	err := row.Scan(
@@ -40,25 +55,98 @@ func (q *Queries) CreateSong(ctx context.Context, arg CreateSongParams) (Song, e
 		&i.ReleaseDate,
 		&i.SongText,
 		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
 	)
 	return i, err
 }
 
-const deleteSong = `-- name: DeleteSong :exec
-DELETE FROM songs WHERE id = $1
+const deleteAllSongs = `-- name: DeleteAllSongs :many
+DELETE FROM songs WHERE tenant_id = $1
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
 `
 
-func (q *Queries) DeleteSong(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, deleteSong, id)
-	return err
+func (q *Queries) DeleteAllSongs(ctx context.Context, tenantID int32) ([]Song, error) {
+	rows, err := q.db.QueryContext(ctx, deleteAllSongs, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Song
+	for rows.Next() {
+		var i Song
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupName,
+			&i.Song,
+			&i.ReleaseDate,
+			&i.SongText,
+			&i.Link,
+			&i.ReleaseDatePrecision,
+			&i.Version,
+			&i.TenantID,
+			&i.DeletedAt,
+			&i.GroupID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteSong = `-- name: DeleteSong :one
+UPDATE songs SET deleted_at = now()
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+  AND ($3::int IS NULL OR version = $3)
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
+`
+
+type DeleteSongParams struct {
+	ID              int32
+	TenantID        int32
+	ExpectedVersion sql.NullInt32
+}
+
+func (q *Queries) DeleteSong(ctx context.Context, arg DeleteSongParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, deleteSong, arg.ID, arg.TenantID, arg.ExpectedVersion)
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
+	)
+	return i, err
 }
 
 const getSongByID = `-- name: GetSongByID :one
-SELECT id, group_name, song, release_date, song_text, link FROM songs WHERE id = $1
+SELECT id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id FROM songs WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
 `
 
-func (q *Queries) GetSongByID(ctx context.Context, id int32) (Song, error) {
-	row := q.db.QueryRowContext(ctx, getSongByID, id)
+type GetSongByIDParams struct {
+	ID       int32
+	TenantID int32
+}
+
+func (q *Queries) GetSongByID(ctx context.Context, arg GetSongByIDParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, getSongByID, arg.ID, arg.TenantID)
 	var i Song
 	err := row.Scan(
 		&i.ID,
@@ -67,36 +155,154 @@ func (q *Queries) GetSongByID(ctx context.Context, id int32) (Song, error) {
 		&i.ReleaseDate,
 		&i.SongText,
 		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
+	)
+	return i, err
+}
+
+const getSongByName = `-- name: GetSongByName :one
+SELECT id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id FROM songs
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND normalize_name(group_name) = normalize_name($2::text)
+  AND normalize_name(song) = normalize_name($3::text)
+`
+
+type GetSongByNameParams struct {
+	TenantID  int32
+	GroupName string
+	Song      string
+}
+
+func (q *Queries) GetSongByName(ctx context.Context, arg GetSongByNameParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, getSongByName, arg.TenantID, arg.GroupName, arg.Song)
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
 	)
 	return i, err
 }
 
 const getSongs = `-- name: GetSongs :many
-SELECT id, group_name, song, release_date, song_text, link FROM songs
-WHERE ($1::text IS NULL OR group_name = $1)
-  AND ($2::text IS NULL OR song = $2)
-  AND ($3::text IS NULL OR release_date = $3)
+SELECT id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id FROM songs
+WHERE tenant_id = $1 AND deleted_at IS NULL
+  AND ($2::text IS NULL OR group_name = $2)
+  AND ($3::int IS NULL OR group_id = $3)
+  AND ($4::text IS NULL OR song = $4)
+  AND ($5::date IS NULL OR release_date >= $5)
+  AND ($6::date IS NULL OR release_date <= $6)
+  AND ($7::int IS NULL OR date_part('year', release_date) = $7)
 ORDER BY id
-LIMIT $4
-OFFSET $5
+LIMIT $9
+OFFSET $8
 `
 
 type GetSongsParams struct {
-	Column1 string
-	Column2 string
-	Column3 string
-	Limit   int32
-	Offset  int32
+	TenantID       int32
+	GroupName      sql.NullString
+	GroupID        sql.NullInt32
+	Song           sql.NullString
+	ReleasedAfter  sql.NullTime
+	ReleasedBefore sql.NullTime
+	Year           sql.NullInt32
+	Offset         sql.NullInt32
+	Limit          sql.NullInt32
 }
 
 func (q *Queries) GetSongs(ctx context.Context, arg GetSongsParams) ([]Song, error) {
 	rows, err := q.db.QueryContext(ctx, getSongs,
-		arg.Column1,
-		arg.Column2,
-		arg.Column3,
-		arg.Limit,
+		arg.TenantID,
+		arg.GroupName,
+		arg.GroupID,
+		arg.Song,
+		arg.ReleasedAfter,
+		arg.ReleasedBefore,
+		arg.Year,
 		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Song
+	for rows.Next() {
+		var i Song
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupName,
+			&i.Song,
+			&i.ReleaseDate,
+			&i.SongText,
+			&i.Link,
+			&i.ReleaseDatePrecision,
+			&i.Version,
+			&i.TenantID,
+			&i.DeletedAt,
+			&i.GroupID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTrashedSong = `-- name: GetTrashedSong :one
+SELECT id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id FROM songs WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL
+`
+
+type GetTrashedSongParams struct {
+	ID       int32
+	TenantID int32
+}
+
+func (q *Queries) GetTrashedSong(ctx context.Context, arg GetTrashedSongParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, getTrashedSong, arg.ID, arg.TenantID)
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
 	)
+	return i, err
+}
+
+const listTrash = `-- name: ListTrash :many
+SELECT id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id FROM songs
+WHERE tenant_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id
+`
+
+func (q *Queries) ListTrash(ctx context.Context, tenantID int32) ([]Song, error) {
+	rows, err := q.db.QueryContext(ctx, listTrash, tenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -111,6 +317,11 @@ func (q *Queries) GetSongs(ctx context.Context, arg GetSongsParams) ([]Song, err
 			&i.ReleaseDate,
 			&i.SongText,
 			&i.Link,
+			&i.ReleaseDatePrecision,
+			&i.Version,
+			&i.TenantID,
+			&i.DeletedAt,
+			&i.GroupID,
 		); err != nil {
 			return nil, err
 		}
@@ -125,28 +336,230 @@ func (q *Queries) GetSongs(ctx context.Context, arg GetSongsParams) ([]Song, err
 	return items, nil
 }
 
-const updateSong = `-- name: UpdateSong :exec
-UPDATE songs SET group_name = $2, song = $3, release_date = $4, song_text = $5, link = $6
-WHERE id = $1
+const purgeDeletedSongs = `-- name: PurgeDeletedSongs :execrows
+DELETE FROM songs WHERE deleted_at < $1
+`
+
+func (q *Queries) PurgeDeletedSongs(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedSongs, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resetSongsIDSequence = `-- name: ResetSongsIDSequence :exec
+SELECT setval(pg_get_serial_sequence('songs', 'id'), COALESCE(last_id, 1), last_id IS NOT NULL)
+FROM (SELECT GREATEST((SELECT MAX(id) FROM songs), (SELECT MAX(song_id) FROM song_revisions)) AS last_id) AS ids
+`
+
+// ID удаленных песен с историей тоже заняты, чтобы история не досталась новой песне
+func (q *Queries) ResetSongsIDSequence(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, resetSongsIDSequence)
+	return err
+}
+
+const restoreSong = `-- name: RestoreSong :one
+INSERT INTO songs (id, group_name, song, release_date, release_date_precision, song_text, link, version, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
+`
+
+type RestoreSongParams struct {
+	ID                   int32
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	Version              int32
+	TenantID             int32
+}
+
+func (q *Queries) RestoreSong(ctx context.Context, arg RestoreSongParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, restoreSong,
+		arg.ID,
+		arg.GroupName,
+		arg.Song,
+		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
+		arg.SongText,
+		arg.Link,
+		arg.Version,
+		arg.TenantID,
+	)
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
+	)
+	return i, err
+}
+
+const undeleteSong = `-- name: UndeleteSong :one
+UPDATE songs SET group_name = $3, song = $4, release_date = $5, release_date_precision = $6, song_text = $7, link = $8,
+    version = version + 1, deleted_at = NULL
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
+`
+
+type UndeleteSongParams struct {
+	ID                   int32
+	TenantID             int32
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+}
+
+func (q *Queries) UndeleteSong(ctx context.Context, arg UndeleteSongParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, undeleteSong,
+		arg.ID,
+		arg.TenantID,
+		arg.GroupName,
+		arg.Song,
+		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
+		arg.SongText,
+		arg.Link,
+	)
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
+	)
+	return i, err
+}
+
+const updateSong = `-- name: UpdateSong :one
+UPDATE songs SET group_name = $2, song = $3, release_date = $4, release_date_precision = $5, song_text = $6, link = $7,
+    version = version + 1
+WHERE id = $1 AND tenant_id = $8 AND deleted_at IS NULL
+  AND ($9::int IS NULL OR version = $9)
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, deleted_at, group_id
 `
 
 type UpdateSongParams struct {
-	ID          int32
-	GroupName   string
-	Song        string
-	ReleaseDate sql.NullString
-	SongText    sql.NullString
-	Link        sql.NullString
+	ID                   int32
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	TenantID             int32
+	ExpectedVersion      sql.NullInt32
 }
 
-func (q *Queries) UpdateSong(ctx context.Context, arg UpdateSongParams) error {
-	_, err := q.db.ExecContext(ctx, updateSong,
+func (q *Queries) UpdateSong(ctx context.Context, arg UpdateSongParams) (Song, error) {
+	row := q.db.QueryRowContext(ctx, updateSong,
 		arg.ID,
 		arg.GroupName,
 		arg.Song,
 		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
 		arg.SongText,
 		arg.Link,
+		arg.TenantID,
+		arg.ExpectedVersion,
 	)
-	return err
+	var i Song
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.DeletedAt,
+		&i.GroupID,
+	)
+	return i, err
+}
+
+const upsertSong = `-- name: UpsertSong :one
+INSERT INTO songs (group_name, song, release_date, release_date_precision, song_text, link, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (tenant_id, normalize_name(group_name), normalize_name(song)) WHERE deleted_at IS NULL DO UPDATE
+SET release_date = EXCLUDED.release_date,
+    release_date_precision = EXCLUDED.release_date_precision,
+    song_text = EXCLUDED.song_text,
+    link = EXCLUDED.link,
+    version = songs.version + 1
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, group_id, (xmax = 0)::boolean AS inserted
+`
+
+type UpsertSongParams struct {
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	TenantID             int32
+}
+
+type UpsertSongRow struct {
+	ID                   int32
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	SongText             sql.NullString
+	Link                 sql.NullString
+	ReleaseDatePrecision DatePrecision
+	Version              int32
+	TenantID             int32
+	GroupID              int32
+	Inserted             bool
+}
+
+func (q *Queries) UpsertSong(ctx context.Context, arg UpsertSongParams) (UpsertSongRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertSong,
+		arg.GroupName,
+		arg.Song,
+		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
+		arg.SongText,
+		arg.Link,
+		arg.TenantID,
+	)
+	var i UpsertSongRow
+	err := row.Scan(
+		&i.ID,
+		&i.GroupName,
+		&i.Song,
+		&i.ReleaseDate,
+		&i.SongText,
+		&i.Link,
+		&i.ReleaseDatePrecision,
+		&i.Version,
+		&i.TenantID,
+		&i.GroupID,
+		&i.Inserted,
+	)
+	return i, err
 }
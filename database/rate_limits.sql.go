@@ -0,0 +1,63 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: rate_limits.sql
+
+package database
+
+import (
+	"context"
+)
+
+const deleteExpiredRateLimitBuckets = `-- name: DeleteExpiredRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredRateLimitBuckets(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredRateLimitBuckets)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at, expires_at)
+VALUES (
+    $1,
+    $2::float8 - 1,
+    true,
+    now(),
+    now() + make_interval(secs => $2::float8 / $3::float8)
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN rate_limit_refill(b.tokens, b.updated_at, $2::float8, $3::float8) >= 1
+        THEN rate_limit_refill(b.tokens, b.updated_at, $2::float8, $3::float8) - 1
+        ELSE rate_limit_refill(b.tokens, b.updated_at, $2::float8, $3::float8)
+    END,
+    allowed = rate_limit_refill(b.tokens, b.updated_at, $2::float8, $3::float8) >= 1,
+    updated_at = now(),
+    expires_at = EXCLUDED.expires_at
+RETURNING tokens, allowed
+`
+
+type TakeRateLimitTokenParams struct {
+	Key   string
+	Burst float64
+	Rate  float64
+}
+
+type TakeRateLimitTokenRow struct {
+	Tokens  float64
+	Allowed bool
+}
+
+// Пополняет корзину за время с прошлого запроса и забирает токен, если он есть.
+// Все вычисления в одном запросе, поэтому экземпляры не мешают друг другу.
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, takeRateLimitToken, arg.Key, arg.Burst, arg.Rate)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReleaseDate — дата выпуска песни с учетом точности: внешний API может
+// вернуть полную дату, только месяц и год или только год.
+type ReleaseDate struct {
+	Time      time.Time
+	Precision DatePrecision
+	Valid     bool
+}
+
+// Форматы, которые понимает ParseReleaseDate, от самого точного к наименее точному.
+var releaseDateLayouts = []struct {
+	layout    string
+	precision DatePrecision
+}{
+	{"2.1.2006", DatePrecisionDay},
+	{"2006-01-02", DatePrecisionDay},
+	{"02/01/2006", DatePrecisionDay},
+	{time.RFC3339, DatePrecisionDay},
+	{"1.2006", DatePrecisionMonth},
+	{"2006-01", DatePrecisionMonth},
+	{"01/2006", DatePrecisionMonth},
+	{"2006", DatePrecisionYear},
+}
+
+// ParseReleaseDate разбирает дату выпуска в одном из форматов внешнего API
+// ("16.07.2006", "2006-07-16", "07.2006", "2006-07", "2006" и т.п.).
+// Пустая строка дает невалидную (NULL) дату без ошибки.
+func ParseReleaseDate(s string) (ReleaseDate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ReleaseDate{}, nil
+	}
+	for _, l := range releaseDateLayouts {
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			continue
+		}
+		y, m, d := t.Date()
+		return ReleaseDate{
+			Time:      time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+			Precision: l.precision,
+			Valid:     true,
+		}, nil
+	}
+	return ReleaseDate{}, fmt.Errorf("unrecognized release date %q", s)
+}
+
+// End возвращает последний день периода, который покрывает дата:
+// для года — 31 декабря, для месяца — последний день месяца.
+func (d ReleaseDate) End() time.Time {
+	switch d.Precision {
+	case DatePrecisionYear:
+		return d.Time.AddDate(1, 0, -1)
+	case DatePrecisionMonth:
+		return d.Time.AddDate(0, 1, -1)
+	default:
+		return d.Time
+	}
+}
+
+// String форматирует дату в ISO 8601 с сохранением точности:
+// "2006-07-16", "2006-07" или "2006".
+func (d ReleaseDate) String() string {
+	if !d.Valid {
+		return ""
+	}
+	switch d.Precision {
+	case DatePrecisionYear:
+		return d.Time.Format("2006")
+	case DatePrecisionMonth:
+		return d.Time.Format("2006-01")
+	default:
+		return d.Time.Format("2006-01-02")
+	}
+}
+
+func (d ReleaseDate) MarshalJSON() ([]byte, error) {
+	if !d.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.String())
+}
+
+func (d *ReleaseDate) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = ReleaseDate{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseReleaseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Release возвращает дату выпуска песни вместе с ее точностью.
+func (s Song) Release() ReleaseDate {
+	return ReleaseDate{
+		Time:      s.ReleaseDate.Time,
+		Precision: s.ReleaseDatePrecision,
+		Valid:     s.ReleaseDate.Valid,
+	}
+}
+
+// SetRelease сохраняет дату выпуска и ее точность в полях песни.
+func (s *Song) SetRelease(d ReleaseDate) {
+	s.ReleaseDate = sql.NullTime{Time: d.Time, Valid: d.Valid}
+	s.ReleaseDatePrecision = d.Precision
+	if s.ReleaseDatePrecision == "" {
+		s.ReleaseDatePrecision = DatePrecisionDay
+	}
+}
+
+// MarshalJSON выводит дату выпуска в едином формате (см. ReleaseDate.String).
+func (s Song) MarshalJSON() ([]byte, error) {
+	type song Song
+	return json.Marshal(struct {
+		song
+		ReleaseDate ReleaseDate `json:"releaseDate"`
+	}{song(s), s.Release()})
+}
+
+// UnmarshalJSON принимает дату выпуска в любом формате ParseReleaseDate.
+func (s *Song) UnmarshalJSON(data []byte) error {
+	type song Song
+	aux := struct {
+		*song
+		ReleaseDate ReleaseDate `json:"releaseDate"`
+	}{song: (*song)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	s.SetRelease(aux.ReleaseDate)
+	return nil
+}
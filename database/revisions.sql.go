@@ -0,0 +1,108 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: revisions.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createSongRevision = `-- name: CreateSongRevision :exec
+INSERT INTO song_revisions (tenant_id, song_id, revision, action, actor, reverted_from,
+    group_name, song, release_date, release_date_precision, song_text, link, version, group_id)
+SELECT $1::int, $2::int, COALESCE(MAX(revision), 0) + 1, $3::text,
+    $4::text, $5::int,
+    $6::text, $7::text, $8::date, $9::date_precision,
+    $10::text, $11::text, $12::int, $13::int
+FROM song_revisions
+WHERE song_id = $2::int
+`
+
+type CreateSongRevisionParams struct {
+	TenantID             int32
+	SongID               int32
+	Action               string
+	Actor                sql.NullString
+	RevertedFrom         sql.NullInt32
+	GroupName            string
+	Song                 string
+	ReleaseDate          sql.NullTime
+	ReleaseDatePrecision DatePrecision
+	SongText             sql.NullString
+	Link                 sql.NullString
+	Version              int32
+	GroupID              sql.NullInt32
+}
+
+func (q *Queries) CreateSongRevision(ctx context.Context, arg CreateSongRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createSongRevision,
+		arg.TenantID,
+		arg.SongID,
+		arg.Action,
+		arg.Actor,
+		arg.RevertedFrom,
+		arg.GroupName,
+		arg.Song,
+		arg.ReleaseDate,
+		arg.ReleaseDatePrecision,
+		arg.SongText,
+		arg.Link,
+		arg.Version,
+		arg.GroupID,
+	)
+	return err
+}
+
+const listSongRevisions = `-- name: ListSongRevisions :many
+SELECT id, tenant_id, song_id, revision, action, actor, reverted_from, created_at, group_name, song, release_date, release_date_precision, song_text, link, version, group_id FROM song_revisions
+WHERE song_id = $1 AND tenant_id = $2
+ORDER BY revision
+`
+
+type ListSongRevisionsParams struct {
+	SongID   int32
+	TenantID int32
+}
+
+func (q *Queries) ListSongRevisions(ctx context.Context, arg ListSongRevisionsParams) ([]SongRevision, error) {
+	rows, err := q.db.QueryContext(ctx, listSongRevisions, arg.SongID, arg.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SongRevision
+	for rows.Next() {
+		var i SongRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.TenantID,
+			&i.SongID,
+			&i.Revision,
+			&i.Action,
+			&i.Actor,
+			&i.RevertedFrom,
+			&i.CreatedAt,
+			&i.GroupName,
+			&i.Song,
+			&i.ReleaseDate,
+			&i.ReleaseDatePrecision,
+			&i.SongText,
+			&i.Link,
+			&i.Version,
+			&i.GroupID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import "context"
+
+// StreamSongs выполняет запрос GetSongs и передает найденные песни в fn по одной,
+// читая результат через курсор, а не загружая его в память целиком.
+// Порядок параметров и колонок должен совпадать с GetSongs из query.sql.go.
+func (q *Queries) StreamSongs(ctx context.Context, arg GetSongsParams, fn func(*Song) error) error {
+	rows, err := q.db.QueryContext(ctx, getSongs,
+		arg.TenantID,
+		arg.GroupName,
+		arg.GroupID,
+		arg.Song,
+		arg.ReleasedAfter,
+		arg.ReleasedBefore,
+		arg.Year,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var i Song
+	for rows.Next() {
+		if err := rows.Scan(
+			&i.ID,
+			&i.GroupName,
+			&i.Song,
+			&i.ReleaseDate,
+			&i.SongText,
+			&i.Link,
+			&i.ReleaseDatePrecision,
+			&i.Version,
+			&i.TenantID,
+			&i.DeletedAt,
+			&i.GroupID,
+		); err != nil {
+			return err
+		}
+		if err := fn(&i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Kitrop/songGO-lib/auth"
+)
+
+// Сколько записей журнала отдавать по умолчанию и максимум.
+const (
+	defaultDenialsLimit = 100
+	maxDenialsLimit     = 1000
+)
+
+// AuditHandler отдает журнал отклоненных запросов.
+type AuditHandler struct {
+	Audit auth.AuditStore
+}
+
+func NewAuditHandler(audit auth.AuditStore) *AuditHandler {
+	return &AuditHandler{Audit: audit}
+}
+
+// Журнал отказов в доступе
+// @Summary List denied requests
+// @Description Requests rejected with 403 because the credential's role was too low, newest first.
+// @Description The log covers all tenants, so tenant-bound credentials are rejected.
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Maximum number of entries" default(100) maximum(1000)
+// @Success 200 {array} auth.Denial
+// @Failure 400 {string} Invalid limit
+// @Failure 403 {string} Requires the admin role not bound to a tenant
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /admin/access-denials [get]
+func (h *AuditHandler) ListDenials(w http.ResponseWriter, r *http.Request) {
+	if !allowTenantAdmin(w, r) {
+		return
+	}
+
+	limit := defaultDenialsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDenialsLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	denials, err := h.Audit.Recent(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "failed to read the audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(denials)
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+)
+
+// Максимальное количество операций в одном пакетном запросе.
+const maxBatchSize = 5000
+
+// Режимы выполнения пакета.
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best-effort"
+)
+
+type BatchRequest struct {
+	// atomic (по умолчанию) — все или ничего; best-effort — ошибочные операции пропускаются
+	Mode       string           `json:"mode" enums:"atomic,best-effort" example:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchOperation struct {
+	Op string `json:"op" enums:"create,update,delete" example:"create"`
+	// ID песни для update и delete
+	ID int32 `json:"id,omitempty" example:"1"`
+	// Ожидаемая версия песни для update и delete (как If-Match)
+	ExpectedVersion int32 `json:"expectedVersion,omitempty" example:"1"`
+	// Данные песни для create и update
+	Song *database.Song `json:"song,omitempty"`
+}
+
+type BatchItemResult struct {
+	Index  int            `json:"index" example:"0"`
+	Op     string         `json:"op" example:"create"`
+	Status int            `json:"status" example:"201"`
+	ID     int32          `json:"id,omitempty" example:"1"`
+	Song   *database.Song `json:"song,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      string            `json:"mode" example:"atomic"`
+	Succeeded int               `json:"succeeded" example:"1"`
+	Failed    int               `json:"failed" example:"0"`
+	Results   []BatchItemResult `json:"results"`
+}
+
+// Пакетно создать, обновить и удалить песни
+// @Summary Batch create, update and delete songs
+// @Description Executes up to 5000 operations in a single transaction. Songs are stored as given,
+// @Description without the external API lookup. In atomic mode the first failing operation rolls back
+// @Description the whole batch (422, other items get status 424); in best-effort mode failing operations
+// @Description are skipped (207 if some failed). Each result carries an HTTP-like status code.
+// @Description Create and update require song and groupName or groupId; an unknown group fails the
+// @Description operation with 422, a groupName that is not the name of the groupId group with 400.
+// @Accept json
+// @Produce json
+// @Param batch body BatchRequest true "Operations"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} BatchResponse "All operations succeeded"
+// @Success 207 {object} BatchResponse "Best-effort batch with failed operations"
+// @Failure 400 {string} Invalid request
+// @Failure 422 {object} BatchResponse "Atomic batch rolled back"
+// @Failure 403 {string} Delete operations require the admin role
+// @Failure 500 {string} Failed to execute batch
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/batch [post]
+func (h *SongHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = batchModeAtomic
+	}
+	if req.Mode != batchModeAtomic && req.Mode != batchModeBestEffort {
+		http.Error(w, fmt.Sprintf("unknown mode %q", req.Mode), http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 {
+		http.Error(w, "operations are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("too many operations: at most %d allowed", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	// Удаление доступно только администраторам, в том числе в пакете
+	for _, op := range req.Operations {
+		if repository.BatchOp(op.Op) == repository.BatchDelete {
+			if !h.Authz.Allow(w, r, auth.RoleAdmin) {
+				return
+			}
+			break
+		}
+	}
+
+	ops := make([]repository.BatchOperation, len(req.Operations))
+	for i, op := range req.Operations {
+		// Пустые названия отклоняет сама операция с кодом 400
+		if op.Song != nil {
+			validateSong(op.Song)
+		}
+		ops[i] = repository.BatchOperation{
+			Op:              repository.BatchOp(op.Op),
+			ID:              op.ID,
+			ExpectedVersion: op.ExpectedVersion,
+			Song:            op.Song,
+		}
+	}
+
+	results, err := h.Repo.ApplyBatch(r.Context(), ops, req.Mode == batchModeAtomic)
+	if err != nil {
+		http.Error(w, "failed to execute batch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := BatchResponse{Mode: req.Mode, Results: make([]BatchItemResult, len(results))}
+	for i, res := range results {
+		item := BatchItemResult{Index: i, Op: req.Operations[i].Op, Status: batchStatus(ops[i].Op, res.Err)}
+		if res.Err != nil {
+			item.Error = res.Err.Error()
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+			item.Song = res.Song
+		}
+		if res.Song != nil {
+			item.ID = res.Song.ID
+		} else {
+			item.ID = ops[i].ID
+		}
+		resp.Results[i] = item
+	}
+
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+		if req.Mode == batchModeAtomic {
+			status = http.StatusUnprocessableEntity
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// batchStatus переводит результат операции пакета в HTTP-код.
+func batchStatus(op repository.BatchOp, err error) int {
+	switch {
+	case err == nil && op == repository.BatchCreate:
+		return http.StatusCreated
+	case err == nil && op == repository.BatchDelete:
+		return http.StatusNoContent
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, repository.ErrBatchAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, repository.ErrSongNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrSongExists):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, repository.ErrQuotaExceeded):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrGroupNotFound):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrInvalidOperation), errors.Is(err, repository.ErrGroupMismatch):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/go-chi/chi"
+)
+
+// songID читает ID песни из пути /songs/{id}. Для совместимости со старыми
+// клиентами также принимается параметр запроса ?id=.
+func songID(r *http.Request) (int32, error) {
+	idStr := chi.URLParam(r, "id")
+	if idStr == "" {
+		idStr = r.URL.Query().Get("id")
+	}
+	id, err := strconv.ParseInt(idStr, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(id), nil
+}
+
+// songETag — сильный ETag песни в формате format, построенный по ее версии:
+// "3" для JSON и "3-xml", "3-yaml" для других форматов. Ответы с Vary: Accept
+// в разных форматах — разные представления, и их сильные ETag должны различаться.
+func songETag(song *database.Song, format responseFormat) string {
+	tag := strconv.Itoa(int(song.Version))
+	if format != formatJSON {
+		tag += "-" + format.name()
+	}
+	return `"` + tag + `"`
+}
+
+// etagList разбирает значение If-Match / If-None-Match в список ETag.
+// wildcard равен true для "*".
+func etagList(header string) (tags []string, wildcard bool) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, false
+}
+
+// notModified проверяет If-None-Match (слабое сравнение, RFC 9110) для
+// представления песни в формате format.
+func notModified(r *http.Request, song *database.Song, format responseFormat) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	tags, wildcard := etagList(header)
+	if wildcard {
+		return true
+	}
+	current := songETag(song, format)
+	for _, tag := range tags {
+		if strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion разбирает If-Match и возвращает версию, которую должна иметь
+// песня, чтобы изменение было выполнено. 0 означает отсутствие условия.
+// Подходит ETag любого представления: условие проверяет только версию.
+// Если в заголовке несколько ETag, они сверяются с текущей версией песни.
+func (h *SongHandler) expectedVersion(r *http.Request, id int32) (int32, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	tags, wildcard := etagList(header)
+	if wildcard {
+		return 0, nil
+	}
+
+	versions := make([]int32, 0, len(tags))
+	for _, tag := range tags {
+		// Слабые ETag не подходят для If-Match (требуется сильное сравнение)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		tag, _, _ = strings.Cut(strings.Trim(tag, `"`), "-")
+		version, err := strconv.ParseInt(tag, 10, 32)
+		if err == nil && version > 0 {
+			versions = append(versions, int32(version))
+		}
+	}
+	if len(versions) == 0 {
+		return 0, repository.ErrVersionMismatch
+	}
+	if len(versions) == 1 {
+		return versions[0], nil
+	}
+
+	song, err := h.Repo.GetSongByID(r.Context(), id)
+	if err != nil {
+		return 0, err
+	}
+	for _, version := range versions {
+		if version == song.Version {
+			return version, nil
+		}
+	}
+	return 0, repository.ErrVersionMismatch
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+// Через сколько песен сбрасывать буфер ответа клиенту.
+const exportFlushEvery = 500
+
+// Сколько времени дается на запись очередной порции выгрузки. Срок
+// продлевается после каждого сброса буфера, так что вся выгрузка может идти
+// дольше WriteTimeout сервера, а клиент, переставший читать ответ, не держит
+// соединение с базой и курсор бесконечно.
+const exportWriteTimeout = time.Minute
+
+// Выгрузить библиотеку
+// @Summary Export the song library
+// @Description Streams all songs matching the same filters as GET /songs, without building the whole list in memory.
+// @Description The response is gzip-compressed when gzip=true or the client sends Accept-Encoding: gzip.
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Produce json
+// @Param format query string false "Output format" Enums(ndjson, csv, json) default(ndjson)
+// @Param gzip query bool false "Compress the output with gzip"
+// @Param group query string false "Exact group name"
+// @Param song query string false "Exact song title"
+// @Param releasedAfter query string false "Released on or after this date" example(2006-01-01)
+// @Param releasedBefore query string false "Released on or before this date" example(2006-12)
+// @Param year query int false "Release year" example(2006)
+// @Success 200 {array} database.Song
+// @Failure 400 {string} Invalid filter or format
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /export [get]
+func (h *SongHandler) Export(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSongFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	var enc songStreamEncoder
+	switch format {
+	case "", "ndjson":
+		format = "ndjson"
+		enc = &ndjsonSongEncoder{}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		enc = &csvSongEncoder{}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case "json":
+		enc = &jsonArraySongEncoder{}
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		http.Error(w, "unsupported export format, expected ndjson, csv or json", http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	useGzip := r.URL.Query().Get("gzip") == "true" || acceptsGzip(r)
+	w.Header().Set("Content-Disposition", `attachment; filename="songs.`+format+`"`)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if useGzip {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if gz != nil {
+			gz.Flush()
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	}
+
+	if err := enc.Begin(out); err != nil {
+		return
+	}
+	count := 0
+	err = h.Repo.StreamSongs(r.Context(), filter, func(song *database.Song) error {
+		if err := enc.Encode(out, song); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		// Заголовки уже отправлены, поэтому просто обрываем выгрузку
+		logger.ErrorContext(r.Context(), "export aborted", "format", format, "songs", count, "error", err)
+		return
+	}
+	enc.End(out)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// songStreamEncoder записывает песни в поток по одной.
+type songStreamEncoder interface {
+	Begin(w io.Writer) error
+	Encode(w io.Writer, song *database.Song) error
+	End(w io.Writer) error
+}
+
+type ndjsonSongEncoder struct{}
+
+func (ndjsonSongEncoder) Begin(io.Writer) error { return nil }
+
+func (ndjsonSongEncoder) Encode(w io.Writer, song *database.Song) error {
+	return json.NewEncoder(w).Encode(song)
+}
+
+func (ndjsonSongEncoder) End(io.Writer) error { return nil }
+
+// jsonArraySongEncoder пишет JSON-массив поэлементно.
+type jsonArraySongEncoder struct {
+	started bool
+}
+
+func (e *jsonArraySongEncoder) Begin(w io.Writer) error {
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+func (e *jsonArraySongEncoder) Encode(w io.Writer, song *database.Song) error {
+	if e.started {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	e.started = true
+	data, err := json.Marshal(song)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (e *jsonArraySongEncoder) End(w io.Writer) error {
+	_, err := io.WriteString(w, "]\n")
+	return err
+}
+
+type csvSongEncoder struct {
+	cw *csv.Writer
+}
+
+// songCSVHeader — колонки CSV-представления песни.
+var songCSVHeader = []string{"id", "group", "song", "releaseDate", "text", "link", "version"}
+
+func songCSVRecord(song *database.Song) []string {
+	return []string{
+		strconv.Itoa(int(song.ID)),
+		song.GroupName,
+		song.Song,
+		song.Release().String(),
+		song.SongText.String,
+		song.Link.String,
+		strconv.Itoa(int(song.Version)),
+	}
+}
+
+func (e *csvSongEncoder) Begin(w io.Writer) error {
+	e.cw = csv.NewWriter(w)
+	return e.cw.Write(songCSVHeader)
+}
+
+func (e *csvSongEncoder) Encode(w io.Writer, song *database.Song) error {
+	if err := e.cw.Write(songCSVRecord(song)); err != nil {
+		return err
+	}
+	// csv.Writer буферизует вывод — сбрасываем, чтобы строки уходили в поток
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+func (e *csvSongEncoder) End(io.Writer) error {
+	e.cw.Flush()
+	return e.cw.Error()
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/go-chi/chi"
+)
+
+// GroupHandler управляет группами библиотеки клиента.
+type GroupHandler struct {
+	Repo repository.Repository
+}
+
+func NewGroupHandler(repo repository.Repository) *GroupHandler {
+	return &GroupHandler{Repo: repo}
+}
+
+// GroupRequest — данные группы. PUT заменяет их целиком: пропущенные поля очищаются.
+type GroupRequest struct {
+	Name string `json:"name" example:"Muse"`
+	// Код страны ISO 3166-1 alpha-2
+	Country    string   `json:"country,omitempty" example:"GB"`
+	FormedYear int32    `json:"formedYear,omitempty" example:"1994"`
+	Aliases    []string `json:"aliases,omitempty" example:"Rocket Baby Dolls"`
+}
+
+// validate проверяет запрос и возвращает группу с нормализованными полями.
+func (req *GroupRequest) validate() (*repository.Group, error) {
+	group := &repository.Group{
+		Name:       strings.TrimSpace(req.Name),
+		Country:    strings.ToUpper(strings.TrimSpace(req.Country)),
+		FormedYear: req.FormedYear,
+		Aliases:    []string{},
+	}
+	if group.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if group.Country != "" && !isCountryCode(group.Country) {
+		return nil, errors.New("country must be an ISO 3166-1 alpha-2 code, e.g. GB")
+	}
+	if group.FormedYear != 0 && (group.FormedYear < 1000 || int(group.FormedYear) > time.Now().Year()) {
+		return nil, errors.New("formedYear must be a year between 1000 and the current year")
+	}
+
+	// Псевдонимы без пустых и повторов, в том числе совпадающих с именем
+	seen := map[string]bool{repository.NormalizeName(group.Name): true}
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		key := repository.NormalizeName(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		group.Aliases = append(group.Aliases, alias)
+	}
+	return group, nil
+}
+
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
+}
+
+// groupID читает ID группы из пути /groups/{id}.
+func groupID(r *http.Request) (int32, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(id), nil
+}
+
+// Список групп
+// @Summary List groups
+// @Description Lists the groups of the library sorted by name. Groups are created automatically
+// @Description when a song with a new group name is added; names differing only in case and spaces
+// @Description belong to the same group. q keeps groups whose name or alias contains it.
+// @Tags groups
+// @Produce json
+// @Param q query string false "Part of the group name or alias" example(muse)
+// @Success 200 {array} repository.Group
+// @Failure 500 {string} Failed to fetch groups
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups [get]
+func (h *GroupHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.Repo.ListGroups(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, "failed to fetch groups", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// Получить группу по ID
+// @Summary Get group by ID
+// @Tags groups
+// @Produce json
+// @Param id path int true "Group ID"
+// @Success 200 {object} repository.Group
+// @Failure 400 {string} Invalid group ID
+// @Failure 404 {string} Group not found
+// @Failure 500 {string} Failed to fetch group
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups/{id} [get]
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.group(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
+
+// Песни группы
+// @Summary List songs of a group
+// @Description Lists the songs of the group. Accepts the same filters and response formats as GET /songs.
+// @Tags groups
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Produce text/csv
+// @Param id path int true "Group ID"
+// @Param song query string false "Exact song title"
+// @Param releasedAfter query string false "Released on or after this date" example(2006-01-01)
+// @Param releasedBefore query string false "Released on or before this date" example(2006-12)
+// @Param year query int false "Release year" example(2006)
+// @Success 200 {array} database.Song
+// @Failure 400 {string} Invalid group ID or filter
+// @Failure 404 {string} Group not found
+// @Failure 500 {string} Failed to fetch songs
+// @Failure 406 {string} Not Acceptable
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups/{id}/songs [get]
+func (h *GroupHandler) ListGroupSongs(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, true)
+	if !ok {
+		return
+	}
+	filter, err := parseSongFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group, ok := h.group(w, r)
+	if !ok {
+		return
+	}
+	filter.GroupID = group.ID
+
+	songs, err := h.Repo.GetAllSongs(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "failed to fetch songs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeSongs(w, format, http.StatusOK, songs)
+}
+
+// Добавить группу
+// @Summary Create a group
+// @Description Creates a group with its metadata before any of its songs are added.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param data body GroupRequest true "Group"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 201 {object} repository.Group
+// @Header 201 {string} Location "URL of the created group"
+// @Failure 400 {string} Invalid request
+// @Failure 409 {string} Group already exists
+// @Failure 500 {string} Failed to create group
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups [post]
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request")
+		return
+	}
+	group, err := req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.Repo.CreateGroup(r.Context(), group)
+	if errors.Is(err, repository.ErrGroupExists) {
+		http.Error(w, "group already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create group: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/groups/"+strconv.Itoa(int(created.ID)))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Обновить группу
+// @Summary Update a group
+// @Description Replaces the group name and metadata; omitted fields are cleared. Renaming a group
+// @Description renames all its songs at once: each song gets a new version and a history revision.
+// @Tags groups
+// @Accept json
+// @Produce json
+// @Param id path int true "Group ID"
+// @Param data body GroupRequest true "Group"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} repository.Group
+// @Failure 400 {string} Invalid group ID or request
+// @Failure 404 {string} Group not found
+// @Failure 409 {string} Another group with the same name exists
+// @Failure 500 {string} Failed to update group
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups/{id} [put]
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := groupID(r)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+	var req GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request")
+		return
+	}
+	group, err := req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group.ID = id
+
+	updated, err := h.Repo.UpdateGroup(r.Context(), group)
+	switch {
+	case errors.Is(err, repository.ErrGroupNotFound):
+		http.Error(w, "group not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrGroupExists):
+		http.Error(w, "another group with the same name exists", http.StatusConflict)
+	case err != nil:
+		http.Error(w, "failed to update group: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+	}
+}
+
+// Удалить группу
+// @Summary Delete a group
+// @Description Deletes a group that has no songs. Songs in the trash still belong to their group,
+// @Description so the group can be deleted only after they are restored elsewhere or purged.
+// @Tags groups
+// @Param id path int true "Group ID"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 204 {string} No Content
+// @Failure 400 {string} Invalid group ID
+// @Failure 404 {string} Group not found
+// @Failure 409 {string} Group has songs
+// @Failure 500 {string} Failed to delete group
+// @Failure 403 {string} Requires the admin role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /groups/{id} [delete]
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := groupID(r)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Repo.DeleteGroup(r.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrGroupNotFound):
+		http.Error(w, "group not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrGroupNotEmpty):
+		http.Error(w, "group has songs, including songs in the trash", http.StatusConflict)
+	case err != nil:
+		http.Error(w, "failed to delete group: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// group читает группу из пути /groups/{id} и отвечает ошибкой, если это не удалось.
+func (h *GroupHandler) group(w http.ResponseWriter, r *http.Request) (*repository.Group, bool) {
+	id, err := groupID(r)
+	if err != nil {
+		http.Error(w, "invalid group ID", http.StatusBadRequest)
+		return nil, false
+	}
+	group, err := h.Repo.GetGroup(r.Context(), id)
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch group", http.StatusInternalServerError)
+		return nil, false
+	}
+	return group, true
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/config"
+	"github.com/Kitrop/songGO-lib/musicapi"
+)
+
+// Сколько ждать ответа базы данных в проверке готовности.
+const healthCheckTimeout = 2 * time.Second
+
+// Состояния компонентов в /readyz и /status.
+const (
+	statusUp       = "up"
+	statusDown     = "down"
+	statusDisabled = "disabled"
+	// statusDegraded — компонент сбоит, но сервис может работать без него.
+	// На готовность не влияет.
+	statusDegraded = "degraded"
+)
+
+// HealthHandler отвечает на проверки живости и готовности.
+type HealthHandler struct {
+	// DB равна nil для хранилища memory: проверки базы и миграций отключаются.
+	DB       *sql.DB
+	MusicAPI *musicapi.Client
+	// Version — версия сборки.
+	Version string
+	// SchemaVersion — версия схемы, которую ожидает приложение.
+	SchemaVersion uint
+
+	started      time.Time
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(db *sql.DB, api *musicapi.Client, version string, schemaVersion uint) *HealthHandler {
+	return &HealthHandler{DB: db, MusicAPI: api, Version: version, SchemaVersion: schemaVersion, started: time.Now()}
+}
+
+// SetShuttingDown переводит /readyz в неготовое состояние, чтобы балансировщик
+// перестал направлять запросы до остановки сервера.
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// ComponentStatus — состояние одного компонента.
+type ComponentStatus struct {
+	Status  string `json:"status" enums:"up,down,disabled,degraded" example:"up"`
+	Latency string `json:"latency,omitempty" example:"1.2ms"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// ReadinessResponse — ответ /readyz.
+type ReadinessResponse struct {
+	Ready        bool                       `json:"ready" example:"true"`
+	ShuttingDown bool                       `json:"shuttingDown" example:"false"`
+	Components   map[string]ComponentStatus `json:"components"`
+}
+
+// StatusResponse — ответ /status.
+type StatusResponse struct {
+	ReadinessResponse
+	Version   string    `json:"version" example:"v1.4.0"`
+	GoVersion string    `json:"goVersion" example:"go1.23.2"`
+	StartedAt time.Time `json:"startedAt"`
+	Uptime    string    `json:"uptime" example:"3h12m5s"`
+}
+
+// Проверка живости
+// @Summary Liveness probe
+// @Description Returns 200 while the process is running.
+// @Tags health
+// @Produce plain
+// @Success 200 {string} ok
+// @Router /healthz [get]
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+// Проверка готовности
+// @Summary Readiness probe
+// @Description Checks the database connection and that the schema is at the expected migration version.
+// @Description Returns 503 if any check fails or the server is shutting down. The external API state is
+// @Description reported as externalApi (degraded after consecutive failures) but never fails the probe:
+// @Description reads do not need it.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse
+// @Failure 503 {object} ReadinessResponse
+// @Router /readyz [get]
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := h.readiness(r.Context())
+	writeHealth(w, resp.Ready, resp)
+}
+
+// Подробное состояние сервиса
+// @Summary Service status
+// @Description Detailed component states, build version and uptime. The status code follows /readyz.
+// @Description As in /readyz, a degraded external API does not affect readiness.
+// @Tags health
+// @Produce json
+// @Success 200 {object} StatusResponse
+// @Failure 503 {object} StatusResponse
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /status [get]
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	resp := StatusResponse{
+		ReadinessResponse: h.readiness(r.Context()),
+		Version:           h.Version,
+		GoVersion:         runtime.Version(),
+		StartedAt:         h.started,
+		Uptime:            time.Since(h.started).Round(time.Second).String(),
+	}
+	writeHealth(w, resp.Ready, resp)
+}
+
+func writeHealth(w http.ResponseWriter, ready bool, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+func (h *HealthHandler) readiness(ctx context.Context) ReadinessResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	resp := ReadinessResponse{
+		ShuttingDown: h.shuttingDown.Load(),
+		Components: map[string]ComponentStatus{
+			"database":   h.checkDatabase(ctx),
+			"migrations": h.checkMigrations(ctx),
+			// Внешний API только показывается: statusDegraded на готовность не влияет
+			"externalApi": h.checkMusicAPI(),
+		},
+	}
+	resp.Ready = !resp.ShuttingDown
+	for _, c := range resp.Components {
+		if c.Status == statusDown {
+			resp.Ready = false
+		}
+	}
+	return resp
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) ComponentStatus {
+	if h.DB == nil {
+		return ComponentStatus{Status: statusDisabled}
+	}
+	start := time.Now()
+	err := h.DB.PingContext(ctx)
+	status := ComponentStatus{Status: statusUp, Latency: time.Since(start).String(), Details: h.DB.Stats()}
+	if err != nil {
+		status.Status = statusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) ComponentStatus {
+	if h.DB == nil {
+		return ComponentStatus{Status: statusDisabled}
+	}
+	version, dirty, err := config.CurrentSchemaVersion(ctx, h.DB)
+	details := map[string]any{"version": version, "expected": h.SchemaVersion, "dirty": dirty}
+	switch {
+	case err != nil:
+		return ComponentStatus{Status: statusDown, Error: err.Error()}
+	case dirty:
+		return ComponentStatus{Status: statusDown, Error: "schema is dirty after a failed migration", Details: details}
+	case version != h.SchemaVersion:
+		return ComponentStatus{Status: statusDown,
+			Error: fmt.Sprintf("schema version %d, expected %d", version, h.SchemaVersion), Details: details}
+	}
+	return ComponentStatus{Status: statusUp, Details: details}
+}
+
+// checkMusicAPI показывает состояние внешнего API. Он нужен лишь для
+// добавления песен, поэтому статус никогда не бывает down: из-за его сбоев
+// реплики не должны выводиться из балансировки.
+func (h *HealthHandler) checkMusicAPI() ComponentStatus {
+	health := h.MusicAPI.Health()
+	if health.State == musicapi.StateFailing {
+		return ComponentStatus{Status: statusDegraded, Error: health.LastError, Details: health}
+	}
+	return ComponentStatus{Status: statusUp, Details: health}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kitrop/songGO-lib/importer"
+	"github.com/Kitrop/songGO-lib/tenant"
+	"github.com/go-chi/chi"
+)
+
+// Сколько последних отчетов об импорте хранится для скачивания.
+const maxImportReports = 100
+
+// Сколько построчных результатов хранится в одном отчете.
+const maxImportReportRows = 10000
+
+// importIdleTimeout — сколько импорт ждет очередную порцию тела запроса. Срок
+// чтения и записи продлевается после каждой прочитанной порции, так что
+// большой импорт может идти дольше таймаутов сервера, а зависший клиент не
+// держит соединение и транзакцию импорта бесконечно.
+const importIdleTimeout = time.Minute
+
+type ImportHandler struct {
+	Importer *importer.Importer
+	reports  *reportStore
+}
+
+func NewImportHandler(imp *importer.Importer) *ImportHandler {
+	return &ImportHandler{Importer: imp, reports: newReportStore(maxImportReports)}
+}
+
+// Импортировать каталог песен
+// @Summary Import songs from CSV or NDJSON
+// @Description Streams the request body and creates a song for every valid row. Songs that already exist
+// @Description are skipped. CSV must have a header row; by default columns are named group, song, releaseDate,
+// @Description text and link, other names can be mapped with the columns parameter. The response is a summary;
+// @Description the per-row report (up to 10000 rows) can be downloaded from the Location URL. The upload may take
+// @Description longer than the server timeouts as long as each chunk of the body arrives within a minute.
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "Input format; defaults to the Content-Type" Enums(csv, ndjson)
+// @Param columns query string false "CSV column mapping, e.g. group=Artist,song=Title,releaseDate=Released"
+// @Param delimiter query string false "CSV delimiter, defaults to a comma"
+// @Param enrich query bool false "Fill missing release date, text and link from the external API"
+// @Param data body string true "CSV or NDJSON content"
+// @Success 200 {object} importer.Report
+// @Failure 400 {string} Invalid import options or unreadable input
+// @Failure 413 {string} Request body too large
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /import [post]
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	formatName := query.Get("format")
+	if formatName == "" {
+		formatName = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+	format, err := importer.ParseFormat(formatName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	columns, err := importer.ParseColumns(query.Get("columns"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := importer.Options{Format: format, Columns: columns, MaxReportRows: maxImportReportRows}
+	if v := query.Get("delimiter"); v != "" {
+		delimiter, size := utf8.DecodeRuneInString(v)
+		if size != len(v) {
+			http.Error(w, "delimiter must be a single character", http.StatusBadRequest)
+			return
+		}
+		opts.Delimiter = delimiter
+	}
+	if v := query.Get("enrich"); v != "" {
+		if opts.Enrich, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid enrich value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.Importer.Import(r.Context(), r.Body, opts)
+	if report == nil {
+		writeBodyError(w, err, "import failed: "+err.Error())
+		return
+	}
+	report.ID = newReportID()
+	h.reports.add(tenant.ID(r.Context()), report)
+
+	location := "/import/" + report.ID + "/report"
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "request body too large; partial report: "+location, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		// Чтение прервано, но уже обработанные строки сохранены — отдаем частичный отчет
+		http.Error(w, "import interrupted: "+err.Error()+"; partial report: "+location, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", location)
+	json.NewEncoder(w).Encode(report.Summary())
+}
+
+// Скачать отчет об импорте
+// @Summary Download an import report
+// @Description Returns the per-row report of a previous import as CSV or JSON.
+// @Produce json
+// @Produce text/csv
+// @Param id path string true "Report ID"
+// @Param format query string false "Report format" Enums(json, csv)
+// @Success 200 {object} importer.Report
+// @Failure 404 {string} Report not found
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /import/{id}/report [get]
+func (h *ImportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	report := h.reports.get(tenant.ID(r.Context()), id)
+	if report == nil {
+		http.Error(w, "report not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="import-`+id+`.csv"`)
+		report.WriteCSV(w)
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="import-`+id+`.json"`)
+		json.NewEncoder(w).Encode(report)
+	default:
+		http.Error(w, "unsupported report format", http.StatusBadRequest)
+	}
+}
+
+// ImportDeadline заменяет таймауты сервера для тела импорта скользящим сроком
+// importIdleTimeout. Подключается до idempotency.Middleware, которое дочитывает
+// тело раньше обработчика.
+func ImportDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &deadlineBody{ReadCloser: r.Body, rc: http.NewResponseController(w)}
+		body.extend()
+		r.Body = body
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deadlineBody продлевает сроки чтения и записи после каждой прочитанной порции.
+type deadlineBody struct {
+	io.ReadCloser
+	rc *http.ResponseController
+}
+
+func (b *deadlineBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.extend()
+	}
+	return n, err
+}
+
+func (b *deadlineBody) extend() {
+	deadline := time.Now().Add(importIdleTimeout)
+	b.rc.SetReadDeadline(deadline)
+	b.rc.SetWriteDeadline(deadline)
+}
+
+func formatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return "ndjson"
+	}
+	return mediaType
+}
+
+func newReportID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// reportStore хранит последние отчеты об импорте в памяти. Отчет доступен
+// только клиенту, который выполнил импорт.
+type reportStore struct {
+	mu      sync.Mutex
+	limit   int
+	order   []reportKey
+	reports map[reportKey]*importer.Report
+}
+
+type reportKey struct {
+	tenantID int32
+	id       string
+}
+
+func newReportStore(limit int) *reportStore {
+	return &reportStore{limit: limit, reports: make(map[reportKey]*importer.Report)}
+}
+
+func (s *reportStore) add(tenantID int32, report *importer.Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.order) == s.limit {
+		delete(s.reports, s.order[0])
+		s.order = s.order[1:]
+	}
+	key := reportKey{tenantID: tenantID, id: report.ID}
+	s.order = append(s.order, key)
+	s.reports[key] = report
+}
+
+func (s *reportStore) get(tenantID int32, id string) *importer.Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reports[reportKey{tenantID: tenantID, id: id}]
+}
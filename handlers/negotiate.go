@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"gopkg.in/yaml.v3"
+)
+
+// responseFormat — формат тела ответа, выбранный по заголовку Accept.
+type responseFormat int
+
+const (
+	formatJSON responseFormat = iota
+	formatXML
+	formatYAML
+	formatCSV
+)
+
+// Поддерживаемые типы в порядке предпочтения сервера.
+var responseMediaTypes = []struct {
+	mediaType string
+	format    responseFormat
+}{
+	{"application/json", formatJSON},
+	{"application/xml", formatXML},
+	{"text/xml", formatXML},
+	{"application/yaml", formatYAML},
+	{"application/x-yaml", formatYAML},
+	{"text/yaml", formatYAML},
+	{"text/csv", formatCSV},
+}
+
+func (f responseFormat) contentType() string {
+	switch f {
+	case formatXML:
+		return "application/xml; charset=utf-8"
+	case formatYAML:
+		return "application/yaml; charset=utf-8"
+	case formatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json"
+}
+
+// name — короткое имя формата для ETag.
+func (f responseFormat) name() string {
+	switch f {
+	case formatXML:
+		return "xml"
+	case formatYAML:
+		return "yaml"
+	case formatCSV:
+		return "csv"
+	}
+	return "json"
+}
+
+// negotiate выбирает формат ответа по заголовку Accept. CSV допустим только
+// для списков. Если ни один тип не подходит, отвечает 406 и возвращает false.
+func negotiate(w http.ResponseWriter, r *http.Request, list bool) (responseFormat, bool) {
+	w.Header().Add("Vary", "Accept")
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return formatJSON, true
+	}
+
+	best, bestQ := formatJSON, 0.0
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, candidate := range responseMediaTypes {
+			if candidate.format == formatCSV && !list {
+				continue
+			}
+			if mediaTypeMatches(mediaType, candidate.mediaType) {
+				best, bestQ = candidate.format, q
+				break
+			}
+		}
+	}
+	if bestQ == 0 {
+		supported := "application/json, application/xml, application/yaml"
+		if list {
+			supported += ", text/csv"
+		}
+		http.Error(w, "not acceptable, supported types: "+supported, http.StatusNotAcceptable)
+		return 0, false
+	}
+	return best, true
+}
+
+// mediaTypeMatches сравнивает диапазон из Accept ("*/*", "application/*") с типом.
+func mediaTypeMatches(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(pattern, "/*")
+	return ok && strings.HasPrefix(mediaType, prefix+"/")
+}
+
+// songDocument — представление песни в XML и YAML.
+type songDocument struct {
+	XMLName     xml.Name `xml:"song" yaml:"-"`
+	ID          int32    `xml:"id" yaml:"id"`
+	GroupName   string   `xml:"groupName" yaml:"groupName"`
+	GroupID     int32    `xml:"groupId,omitempty" yaml:"groupId,omitempty"`
+	Song        string   `xml:"song" yaml:"song"`
+	ReleaseDate string   `xml:"releaseDate,omitempty" yaml:"releaseDate,omitempty"`
+	SongText    string   `xml:"songText,omitempty" yaml:"songText,omitempty"`
+	Link        string   `xml:"link,omitempty" yaml:"link,omitempty"`
+	Version     int32    `xml:"version" yaml:"version"`
+	// DeletedAt и PurgeAt заполняются только для песен из корзины
+	DeletedAt string `xml:"deletedAt,omitempty" yaml:"deletedAt,omitempty"`
+	PurgeAt   string `xml:"purgeAt,omitempty" yaml:"purgeAt,omitempty"`
+}
+
+type songListDocument struct {
+	XMLName xml.Name       `xml:"songs"`
+	Songs   []songDocument `xml:"song"`
+}
+
+func newSongDocument(song *database.Song) songDocument {
+	return songDocument{
+		ID:          song.ID,
+		GroupName:   song.GroupName,
+		GroupID:     song.GroupID,
+		Song:        song.Song,
+		ReleaseDate: song.Release().String(),
+		SongText:    song.SongText.String,
+		Link:        song.Link.String,
+		Version:     song.Version,
+	}
+}
+
+// writeSong отправляет песню в выбранном формате.
+func writeSong(w http.ResponseWriter, format responseFormat, status int, song *database.Song) {
+	w.Header().Set("Content-Type", format.contentType())
+	w.WriteHeader(status)
+	switch format {
+	case formatXML:
+		writeXML(w, newSongDocument(song))
+	case formatYAML:
+		yaml.NewEncoder(w).Encode(newSongDocument(song))
+	default:
+		json.NewEncoder(w).Encode(song)
+	}
+}
+
+// writeSongs отправляет список песен в выбранном формате.
+func writeSongs(w http.ResponseWriter, format responseFormat, status int, songs []*database.Song) {
+	w.Header().Set("Content-Type", format.contentType())
+	w.WriteHeader(status)
+	switch format {
+	case formatXML, formatYAML:
+		docs := make([]songDocument, len(songs))
+		for i, song := range songs {
+			docs[i] = newSongDocument(song)
+		}
+		if format == formatXML {
+			writeXML(w, songListDocument{Songs: docs})
+		} else {
+			yaml.NewEncoder(w).Encode(docs)
+		}
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(songCSVHeader)
+		for _, song := range songs {
+			cw.Write(songCSVRecord(song))
+		}
+		cw.Flush()
+	default:
+		json.NewEncoder(w).Encode(songs)
+	}
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(v)
+	w.Write([]byte("\n"))
+}
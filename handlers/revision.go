@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/go-chi/chi"
+)
+
+// RevisionDiff — разница между двумя ревизиями песни.
+type RevisionDiff struct {
+	From    int32                    `json:"from" example:"1"`
+	To      int32                    `json:"to" example:"3"`
+	Changes []repository.FieldChange `json:"changes"`
+}
+
+// История изменений песни
+// @Summary List song revisions
+// @Description Every create, update, delete and revert of the song as a full snapshot, oldest first.
+// @Description The history of a deleted song stays available, so it can be reverted.
+// @Produce json
+// @Param id path int true "Song ID"
+// @Success 200 {array} repository.Revision
+// @Failure 400 {string} Invalid song ID
+// @Failure 404 {string} Song not found
+// @Failure 500 {string} Failed to fetch revisions
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id}/revisions [get]
+func (h *SongHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, ok := h.revisions(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// Ревизия песни
+// @Summary Get a song revision
+// @Description Returns one revision of the song with its full snapshot.
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param rev path int true "Revision number"
+// @Success 200 {object} repository.Revision
+// @Failure 400 {string} Invalid song ID or revision
+// @Failure 404 {string} Song or revision not found
+// @Failure 500 {string} Failed to fetch revisions
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id}/revisions/{rev} [get]
+func (h *SongHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	rev, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+	revisions, ok := h.revisions(w, r)
+	if !ok {
+		return
+	}
+	revision := findRevision(revisions, int32(rev))
+	if revision == nil {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revision)
+}
+
+// Разница между ревизиями
+// @Summary Diff two song revisions
+// @Description Lists the fields that differ between two revisions. The song text also gets a line diff:
+// @Description lines prefixed with "-" were removed, "+" added, " " unchanged.
+// @Description Without parameters compares the latest revision with the previous one.
+// @Produce json
+// @Param id path int true "Song ID"
+// @Param from query int false "Older revision (default: the one before to)"
+// @Param to query int false "Newer revision (default: the latest)"
+// @Success 200 {object} RevisionDiff
+// @Failure 400 {string} Invalid song ID or revision
+// @Failure 404 {string} Song or revision not found
+// @Failure 500 {string} Failed to fetch revisions
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id}/revisions/diff [get]
+func (h *SongHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, errFrom := optionalRevision(query.Get("from"))
+	to, errTo := optionalRevision(query.Get("to"))
+	if errFrom != nil || errTo != nil {
+		http.Error(w, "from and to must be revision numbers", http.StatusBadRequest)
+		return
+	}
+	revisions, ok := h.revisions(w, r)
+	if !ok {
+		return
+	}
+	if to == 0 {
+		to = revisions[len(revisions)-1].Revision
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	older, newer := findRevision(revisions, from), findRevision(revisions, to)
+	if older == nil || newer == nil {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RevisionDiff{
+		From:    from,
+		To:      to,
+		Changes: repository.DiffSongs(&older.Song, &newer.Song),
+	})
+}
+
+// Откатить песню к ревизии
+// @Summary Revert a song to a revision
+// @Description Restores the song fields from the revision and records the change as a new revision.
+// @Description A deleted song is recreated with its old ID. Revisions that record a deletion cannot be reverted to.
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Param id path int true "Song ID"
+// @Param rev path int true "Revision number"
+// @Param If-Match header string false "ETag of the current song version"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} database.Song
+// @Header 200 {string} ETag "Song version"
+// @Failure 400 {string} Invalid song ID or revision, or the revision records a deletion
+// @Failure 404 {string} Song or revision not found
+// @Failure 409 {string} Another song with the same group and title exists
+// @Failure 412 {string} Song version does not match If-Match
+// @Failure 500 {string} Failed to revert song
+// @Failure 406 {string} Not Acceptable
+// @Failure 403 {string} Requires the editor role, or the tenant song quota is exhausted
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id}/revisions/{rev}/revert [post]
+func (h *SongHandler) RevertSong(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	id, err := songID(r)
+	if err != nil {
+		http.Error(w, "invalid song ID", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 32)
+	if err != nil || rev < 1 {
+		http.Error(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	expected, err := h.expectedVersion(r, id)
+	var song *database.Song
+	if err == nil {
+		song, err = h.Repo.RevertSong(r.Context(), id, int32(rev), expected)
+	}
+	switch {
+	case errors.Is(err, repository.ErrSongNotFound):
+		http.Error(w, "song not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrRevisionNotFound):
+		http.Error(w, "revision not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrDeletionRevision):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, repository.ErrVersionMismatch):
+		http.Error(w, "song version does not match If-Match", http.StatusPreconditionFailed)
+	case errors.Is(err, repository.ErrSongExists):
+		http.Error(w, "another song with the same group and title exists", http.StatusConflict)
+	case errors.Is(err, repository.ErrQuotaExceeded):
+		http.Error(w, quotaExceededMessage, http.StatusForbidden)
+	case err != nil:
+		http.Error(w, "failed to revert song: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("ETag", songETag(song, format))
+		writeSong(w, format, http.StatusOK, song)
+	}
+}
+
+// revisions читает историю песни из пути /songs/{id} и отвечает ошибкой,
+// если это не удалось.
+func (h *SongHandler) revisions(w http.ResponseWriter, r *http.Request) ([]*repository.Revision, bool) {
+	id, err := songID(r)
+	if err != nil {
+		http.Error(w, "invalid song ID", http.StatusBadRequest)
+		return nil, false
+	}
+	revisions, err := h.Repo.ListRevisions(r.Context(), id)
+	if errors.Is(err, repository.ErrSongNotFound) {
+		http.Error(w, "song not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch revisions", http.StatusInternalServerError)
+		return nil, false
+	}
+	return revisions, true
+}
+
+func findRevision(revisions []*repository.Revision, rev int32) *repository.Revision {
+	for _, revision := range revisions {
+		if revision.Revision == rev {
+			return revision
+		}
+	}
+	return nil
+}
+
+// optionalRevision разбирает номер ревизии из параметра запроса; пустой параметр — 0.
+func optionalRevision(s string) (int32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	rev, err := strconv.ParseInt(s, 10, 32)
+	if err != nil || rev < 1 {
+		return 0, errors.New("invalid revision")
+	}
+	return int32(rev), nil
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/go-chi/chi"
+)
+
+func revisionRouter(h *SongHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/songs/{id}/revisions", h.ListRevisions)
+	r.Get("/songs/{id}/revisions/diff", h.DiffRevisions)
+	r.Get("/songs/{id}/revisions/{rev}", h.GetRevision)
+	r.Post("/songs/{id}/revisions/{rev}/revert", h.RevertSong)
+	return r
+}
+
+func do(h http.Handler, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// songWithHistory создает песню с ревизиями create, update и delete.
+func songWithHistory(t *testing.T, repo repository.Repository) *database.Song {
+	t.Helper()
+	ctx := context.Background()
+	song, err := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Uprising",
+		SongText: sql.NullString{String: "a\nb", Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := *song
+	updated.SongText = sql.NullString{String: "a\nc", Valid: true}
+	if err := repo.UpdateSong(ctx, &updated, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteSong(ctx, song.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	return song
+}
+
+func TestRevisionEndpoints(t *testing.T) {
+	repo := repository.NewSongRepository()
+	song := songWithHistory(t, repo)
+	h := revisionRouter(NewSongHandler(repo, nil))
+
+	w := do(h, http.MethodGet, "/songs/1/revisions", nil)
+	var revisions []repository.Revision
+	json.NewDecoder(w.Body).Decode(&revisions)
+	if w.Code != http.StatusOK || len(revisions) != 3 {
+		t.Fatalf("list: status %d, %d revisions, want 200 and 3", w.Code, len(revisions))
+	}
+
+	w = do(h, http.MethodGet, "/songs/1/revisions/diff?from=1&to=2", nil)
+	var diff RevisionDiff
+	json.NewDecoder(w.Body).Decode(&diff)
+	if w.Code != http.StatusOK || len(diff.Changes) != 1 || diff.Changes[0].Field != "songText" {
+		t.Fatalf("diff: status %d, %+v", w.Code, diff)
+	}
+
+	for target, want := range map[string]int{
+		"/songs/1/revisions/9":         http.StatusNotFound,
+		"/songs/2/revisions":           http.StatusNotFound,
+		"/songs/1/revisions/diff?to=9": http.StatusNotFound,
+		"/songs/1/revisions/diff?to=x": http.StatusBadRequest,
+	} {
+		if w := do(h, http.MethodGet, target, nil); w.Code != want {
+			t.Errorf("GET %s: status %d, want %d", target, w.Code, want)
+		}
+	}
+
+	if w := do(h, http.MethodPost, "/songs/1/revisions/3/revert", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("revert to a deletion: status %d, want 400", w.Code)
+	}
+
+	w = do(h, http.MethodPost, "/songs/1/revisions/1/revert", nil)
+	var reverted database.Song
+	json.NewDecoder(w.Body).Decode(&reverted)
+	if w.Code != http.StatusOK || reverted.ID != song.ID || reverted.SongText.String != "a\nb" {
+		t.Fatalf("revert: status %d, song %+v", w.Code, reverted)
+	}
+	if etag := w.Header().Get("ETag"); etag != songETag(&reverted, formatJSON) {
+		t.Errorf("revert ETag = %s, want %s", etag, songETag(&reverted, formatJSON))
+	}
+}
+
+func TestRevertChecksIfMatch(t *testing.T) {
+	repo := repository.NewSongRepository()
+	song, _ := repo.CreateSong(context.Background(), &database.Song{GroupName: "Muse", Song: "Uprising"})
+	h := revisionRouter(NewSongHandler(repo, nil))
+
+	stale := `"99"`
+	if w := do(h, http.MethodPost, "/songs/1/revisions/1/revert", map[string]string{"If-Match": stale}); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", w.Code)
+	}
+	current := songETag(song, formatXML)
+	if w := do(h, http.MethodPost, "/songs/1/revisions/1/revert", map[string]string{"If-Match": current}); w.Code != http.StatusOK {
+		t.Fatalf("If-Match %s: status %d, want 200", current, w.Code)
+	}
+}
@@ -2,144 +2,430 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"database/sql"
 
+	"github.com/Kitrop/songGO-lib/auth"
 	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/Kitrop/songGO-lib/musicapi"
 	"github.com/Kitrop/songGO-lib/repository"
 )
 
+var logger = logging.For(logging.ComponentHandlers)
+
 type SongHandler struct {
-	Repo *repository.SongRepository
+	Repo     repository.Repository
+	MusicAPI *musicapi.Client
+	// Authz проверяет роли внутри обработчиков, где права зависят от тела
+	// запроса (удаление в пакете). nil — проверка отключена.
+	Authz *auth.Authorizer
+	// TrashRetention — сколько песня лежит в корзине до окончательного
+	// удаления; нужна, чтобы показать в корзине срок удаления.
+	TrashRetention time.Duration
 }
 
-func NewSongHandler(repo *repository.SongRepository) *SongHandler {
-	return &SongHandler{Repo: repo}
+func NewSongHandler(repo repository.Repository, api *musicapi.Client) *SongHandler {
+	return &SongHandler{Repo: repo, MusicAPI: api}
 }
 
 // Получить список всех песен
 // @Summary Get all songs
-// @Description Retrieves a list of all songs.
+// @Description Retrieves a list of all songs. Release date bounds are inclusive and accept
+// @Description the same formats as release dates ("2006-07-16", "16.07.2006", "2006-07", "2006");
+// @Description a partial releasedBefore covers the whole month or year.
+// @Description The response format follows the Accept header: JSON (default), XML, YAML or CSV.
 // @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Produce text/csv
+// @Param group query string false "Exact group name"
+// @Param song query string false "Exact song title"
+// @Param releasedAfter query string false "Released on or after this date" example(2006-01-01)
+// @Param releasedBefore query string false "Released on or before this date" example(2006-12)
+// @Param year query int false "Release year" example(2006)
 // @Success 200 {array} database.Song
+// @Failure 400 {string} Invalid filter
 // @Failure 500 {string} Internal Server Error
+// @Failure 406 {string} Not Acceptable
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
 // @Router /songs [get]
 func (h *SongHandler) GetAllSongs(w http.ResponseWriter, r *http.Request) {
-	songs, err := h.Repo.GetAllSongs(r.Context())
+	format, ok := negotiate(w, r, true)
+	if !ok {
+		return
+	}
+	filter, err := parseSongFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	songs, err := h.Repo.GetAllSongs(r.Context(), filter)
 	if err != nil {
 		http.Error(w, "failed to fetch songs: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(songs)
+	writeSongs(w, format, http.StatusOK, songs)
+}
+
+// parseSongFilter собирает фильтр списка песен из параметров запроса.
+func parseSongFilter(r *http.Request) (repository.SongFilter, error) {
+	query := r.URL.Query()
+	filter := repository.SongFilter{
+		Group: query.Get("group"),
+		Song:  query.Get("song"),
+	}
+
+	if v := query.Get("releasedAfter"); v != "" {
+		date, err := database.ParseReleaseDate(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid releasedAfter: %w", err)
+		}
+		filter.ReleasedAfter = date.Time
+	}
+	if v := query.Get("releasedBefore"); v != "" {
+		date, err := database.ParseReleaseDate(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid releasedBefore: %w", err)
+		}
+		filter.ReleasedBefore = date.End()
+	}
+	if v := query.Get("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil || year <= 0 {
+			return filter, fmt.Errorf("invalid year %q", v)
+		}
+		filter.Year = year
+	}
+	return filter, nil
 }
 
 // Получить песню по ID
 // @Summary Get song by ID
-// @Description Retrieves a song by its ID.
+// @Description Retrieves a song by its ID. The ETag header carries the song version and, for formats
+// @Description other than JSON, the format ("3-xml"); send it back in If-None-Match to get
+// @Description 304 Not Modified when the song is unchanged. If-Match accepts the ETag of any format.
 // @Produce json
-// @Param id query int true "Song ID"
+// @Produce xml
+// @Produce application/yaml
+// @Param id path int true "Song ID"
+// @Param If-None-Match header string false "ETag from a previous response"
 // @Success 200 {object} database.Song
+// @Header 200 {string} ETag "Song version"
+// @Success 304 {string} Not Modified
 // @Failure 400 {string} Invalid song ID
 // @Failure 404 {string} Song not found
+// @Failure 500 {string} Failed to fetch song
+// @Failure 406 {string} Not Acceptable
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
 // @Router /songs/{id} [get]
 func (h *SongHandler) GetSongByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	id, err := songID(r)
 	if err != nil {
 		http.Error(w, "invalid song ID", http.StatusBadRequest)
 		return
 	}
 
-	song, err := h.Repo.GetSongByID(r.Context(), int32(id))
-	if err != nil {
+	song, err := h.Repo.GetSongByID(r.Context(), id)
+	if errors.Is(err, repository.ErrSongNotFound) {
 		http.Error(w, "song not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, "failed to fetch song: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(song)
+	w.Header().Set("ETag", songETag(song, format))
+	if notModified(r, song, format) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeSong(w, format, http.StatusOK, song)
 }
 
 type CreateSongRequest struct {
 	Group string `json:"group" example:"Muse"`
-	Song  string `json:"song" example:"Supermassive Black Hole"`
+	// ID существующей группы; если задан, group можно не передавать, а
+	// переданный group должен совпадать с ее именем
+	GroupID int32  `json:"groupId,omitempty" example:"1"`
+	Song    string `json:"song" example:"Supermassive Black Hole"`
 }
 
 // @Summary Create a new song
-// @Description Creates a new song.
+// @Description Creates a new song. The group is given by name or by groupId; groupId must refer
+// @Description to an existing group, and a group sent along with it must be its name (400 otherwise).
 // @Accept json
 // @Produce json
+// @Produce xml
+// @Produce application/yaml
 // @Param song body CreateSongRequest true "Song data"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
 // @Success 201 {object} database.Song
 // @Failure 400 {string} Invalid request
+// @Failure 409 {string} Song already exists, Location points to the existing song
+// @Failure 422 {string} Group not found
 // @Failure 500 {string} Failed to fetch song info from external API or Failed to save song
+// @Failure 406 {string} Not Acceptable
+// @Failure 403 {string} Requires the editor role, or the tenant song quota is exhausted
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
 // @Router /songs [post]
 func (h *SongHandler) CreateSong(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+
 	// Чтение данных запроса
 	var req CreateSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		writeBodyError(w, err, "Invalid request")
+		return
+	}
+	req.Group = strings.TrimSpace(req.Group)
+	req.Song = strings.TrimSpace(req.Song)
+	if (req.Group == "" && req.GroupID == 0) || req.Song == "" {
+		http.Error(w, "group or groupId and song are required", http.StatusBadRequest)
+		return
+	}
+
+	// Название группы по ID нужно для внешнего API
+	if req.GroupID != 0 {
+		group, err := h.Repo.GetGroup(r.Context(), req.GroupID)
+		if errors.Is(err, repository.ErrGroupNotFound) {
+			http.Error(w, groupNotFoundMessage, http.StatusUnprocessableEntity)
 			return
+		}
+		if err != nil {
+			http.Error(w, "failed to fetch group", http.StatusInternalServerError)
+			return
+		}
+		if req.Group != "" && repository.NormalizeName(req.Group) != repository.NormalizeName(group.Name) {
+			http.Error(w, "group does not match the group with groupId", http.StatusBadRequest)
+			return
+		}
+		req.Group = group.Name
 	}
 
-	// Запрос к внешнему API
-	externalApiURL := os.Getenv("EXTERNAL_API_PATH")
-	resp, err := http.Get(externalApiURL + "?group=" + req.Group + "&song=" + req.Song)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		http.Error(w, "Failed to fetch song info from external API", http.StatusInternalServerError)
+	// Не обращаемся к внешнему API, если такая песня уже есть
+	if _, err := h.Repo.GetSongByName(r.Context(), req.Group, req.Song); err == nil {
+		h.writeConflict(w, r, req.Group, req.Song)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Декодирование ответа внешнего API
-	var songInfo struct {
-		ReleaseDate string `json:"releaseDate"`
-		Text        string `json:"text"`
-		Link        string `json:"link"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&songInfo); err != nil {
-		http.Error(w, "Invalid response from external API", http.StatusInternalServerError)
+	// Запрос к внешнему API
+	songInfo, err := h.MusicAPI.SongInfo(r.Context(), req.Group, req.Song)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "external API request failed", "group", req.Group, "song", req.Song, "error", err)
+		http.Error(w, "Failed to fetch song info from external API", http.StatusInternalServerError)
 		return
 	}
 
 	// Создание объекта песни
 	song := &database.Song{
-		GroupName:   req.Group,
-		Song:        req.Song,
-		ReleaseDate: sql.NullString{String: songInfo.ReleaseDate, Valid: true},
-		SongText:    sql.NullString{String: songInfo.Text, Valid: true},
-		Link:        sql.NullString{String: songInfo.Link, Valid: true},
+		GroupName: req.Group,
+		GroupID:   req.GroupID,
+		Song:      req.Song,
+		SongText:  sql.NullString{String: songInfo.Text, Valid: true},
+		Link:      sql.NullString{String: songInfo.Link, Valid: true},
 	}
 
+	releaseDate, err := database.ParseReleaseDate(songInfo.ReleaseDate)
+	if err != nil {
+		logger.WarnContext(r.Context(), "invalid release date from external API", "release_date", songInfo.ReleaseDate, "error", err)
+	}
+	song.SetRelease(releaseDate)
+
 	// Сохранение в базе данных
-	if _, err := h.Repo.CreateSong(r.Context(), song); err != nil {
+	_, err = h.Repo.CreateSong(r.Context(), song)
+	if errors.Is(err, repository.ErrSongExists) {
+		h.writeConflict(w, r, song.GroupName, song.Song)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		http.Error(w, groupNotFoundMessage, http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		http.Error(w, quotaExceededMessage, http.StatusForbidden)
+		return
+	}
+	if err != nil {
 		http.Error(w, "Failed to save song", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(song)
+	w.Header().Set("Location", songPath(song.ID))
+	w.Header().Set("ETag", songETag(song, format))
+	writeSong(w, format, http.StatusCreated, song)
+}
+
+type UpsertSongRequest struct {
+	ReleaseDate database.ReleaseDate `json:"releaseDate" swaggertype:"string" example:"2006-07-16"`
+	SongText    string               `json:"songText" example:"Ooh baby, don't you know I suffer?..."`
+	Link        string               `json:"link" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+}
+
+// Создать или обновить песню по группе и названию
+// @Summary Create or update a song by group and title
+// @Description Idempotent import: creates the song if no song with the same group and title exists
+// @Description (case and whitespace insensitive), otherwise replaces its release date, text and link.
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Param group query string true "Group name"
+// @Param song query string true "Song title"
+// @Param data body UpsertSongRequest true "Song details"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} database.Song "Updated"
+// @Success 201 {object} database.Song "Created"
+// @Failure 400 {string} Invalid request
+// @Failure 500 {string} Failed to save song
+// @Failure 406 {string} Not Acceptable
+// @Failure 403 {string} Requires the editor role, or the tenant song quota is exhausted
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/by-name [put]
+func (h *SongHandler) UpsertSongByName(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	group := strings.TrimSpace(r.URL.Query().Get("group"))
+	title := strings.TrimSpace(r.URL.Query().Get("song"))
+	if group == "" || title == "" {
+		http.Error(w, "group and song are required", http.StatusBadRequest)
+		return
+	}
+
+	var req UpsertSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "invalid request body: "+err.Error())
+		return
+	}
+
+	song := &database.Song{
+		GroupName: group,
+		Song:      title,
+		SongText:  sql.NullString{String: req.SongText, Valid: req.SongText != ""},
+		Link:      sql.NullString{String: req.Link, Valid: req.Link != ""},
+	}
+	song.SetRelease(req.ReleaseDate)
+
+	created, err := h.Repo.UpsertSong(r.Context(), song)
+	if errors.Is(err, repository.ErrQuotaExceeded) {
+		http.Error(w, quotaExceededMessage, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save song: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", songPath(song.ID))
+	w.Header().Set("ETag", songETag(song, format))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeSong(w, format, status, song)
+}
+
+// Ответ на добавление песни сверх квоты клиента.
+const quotaExceededMessage = "song quota of the tenant is exhausted"
+
+// Ответ на groupId несуществующей группы в теле запроса.
+const groupNotFoundMessage = "group not found"
+
+// validateSong обрезает пробелы в названиях группы и песни и проверяет, что
+// песня и группа (по имени или groupId) заданы.
+func validateSong(song *database.Song) error {
+	song.GroupName = strings.TrimSpace(song.GroupName)
+	song.Song = strings.TrimSpace(song.Song)
+	if (song.GroupName == "" && song.GroupID == 0) || song.Song == "" {
+		return errors.New("groupName or groupId and song are required")
+	}
+	return nil
+}
+
+// writeConflict отвечает 409 Conflict и указывает в Location на уже существующую песню.
+func (h *SongHandler) writeConflict(w http.ResponseWriter, r *http.Request, group, song string) {
+	existing, err := h.Repo.GetSongByName(r.Context(), group, song)
+	if err != nil {
+		http.Error(w, "song already exists", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Location", songPath(existing.ID))
+	http.Error(w, "song already exists: "+songPath(existing.ID), http.StatusConflict)
+}
+
+// writeBodyError отвечает 413, если тело запроса превысило допустимый размер,
+// иначе 400 с сообщением msg.
+func writeBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, msg, http.StatusBadRequest)
+}
+
+func songPath(id int32) string {
+	return "/songs/" + strconv.Itoa(int(id))
 }
 
 
 // Обновить существующую песню
 // @Summary Update an existing song
-// @Description Updates an existing song.
+// @Description Updates an existing song. Send the ETag from GET /songs/{id} in If-Match
+// @Description to make sure nobody changed the song in the meantime. A non-zero groupId moves
+// @Description the song to that group; groupName sent along with it must be the name of that group,
+// @Description otherwise the request fails with 400.
 // @Accept json
-// @Param id query int true "Song ID"
+// @Param id path int true "Song ID"
 // @Param song body database.Song true "Song data"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
 // @Success 204 {string} No Content
-// @Failure 400 {string} Invalid song ID or invalid request body
+// @Header 204 {string} ETag "New song version"
+// @Failure 400 {string} Invalid song ID, invalid request body, or groupName does not match groupId
+// @Failure 404 {string} Song not found
+// @Failure 409 {string} Another song with the same group and title exists
+// @Failure 412 {string} Song version does not match If-Match
+// @Failure 422 {string} Group not found
 // @Failure 500 {string} Failed to update song
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
 // @Router /songs/{id} [put]
 func (h *SongHandler) UpdateSong(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := songID(r)
 	if err != nil {
 		http.Error(w, "invalid song ID", http.StatusBadRequest)
 		return
@@ -147,37 +433,215 @@ func (h *SongHandler) UpdateSong(w http.ResponseWriter, r *http.Request) {
 
 	var req database.Song
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeBodyError(w, err, "invalid request body")
 		return
 	}
-	req.ID = int32(id)
+	if err := validateSong(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ID = id
 
-	err = h.Repo.UpdateSong(r.Context(), &req)
+	expected, err := h.expectedVersion(r, id)
+	if err == nil {
+		err = h.Repo.UpdateSong(r.Context(), &req, expected)
+	}
+	if errors.Is(err, repository.ErrSongNotFound) {
+		http.Error(w, "song not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		http.Error(w, "song version does not match If-Match", http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, repository.ErrSongExists) {
+		h.writeConflict(w, r, req.GroupName, req.Song)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		http.Error(w, groupNotFoundMessage, http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to update song: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", songETag(&req, formatJSON))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// PatchSongRequest — частичное обновление песни: изменяются только переданные поля.
+type PatchSongRequest struct {
+	GroupName *string `json:"groupName,omitempty" example:"Muse"`
+	// Перенести песню в существующую группу; groupName, переданный вместе
+	// с ним, должен совпадать с ее именем
+	GroupID     *int32                `json:"groupId,omitempty" example:"1"`
+	Song        *string               `json:"song,omitempty" example:"Supermassive Black Hole"`
+	ReleaseDate *database.ReleaseDate `json:"releaseDate,omitempty" swaggertype:"string" example:"2006-07-16"`
+	SongText    *string               `json:"songText,omitempty" example:"Ooh baby, don't you know I suffer?..."`
+	Link        *string               `json:"link,omitempty" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+}
+
+// Частично обновить песню
+// @Summary Partially update a song
+// @Description Updates only the fields present in the body. An empty string clears
+// @Description releaseDate, songText or link. Without If-Match the update still fails with 412
+// @Description if the song changes between reading and writing it.
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Param id path int true "Song ID"
+// @Param song body PatchSongRequest true "Fields to change"
+// @Param If-Match header string false "ETag of the version being changed"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} database.Song
+// @Header 200 {string} ETag "New song version"
+// @Failure 400 {string} Invalid song ID, invalid request body, or groupName does not match groupId
+// @Failure 404 {string} Song not found
+// @Failure 409 {string} Another song with the same group and title exists
+// @Failure 412 {string} Song version does not match If-Match
+// @Failure 422 {string} Group not found
+// @Failure 500 {string} Failed to update song
+// @Failure 406 {string} Not Acceptable
+// @Failure 403 {string} Requires the editor role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id} [patch]
+func (h *SongHandler) PatchSong(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	id, err := songID(r)
+	if err != nil {
+		http.Error(w, "invalid song ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PatchSongRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "invalid request body: "+err.Error())
+		return
+	}
+
+	expected, err := h.expectedVersion(r, id)
+	var current *database.Song
+	if err == nil {
+		current, err = h.Repo.GetSongByID(r.Context(), id)
+	}
+	var song database.Song
+	if err == nil {
+		// Без If-Match изменяем именно ту версию, которую прочитали
+		if expected == 0 {
+			expected = current.Version
+		}
+		song = *current
+		applyPatch(&song, req)
+		if err := validateSong(&song); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = h.Repo.UpdateSong(r.Context(), &song, expected)
+	}
+	if errors.Is(err, repository.ErrSongNotFound) {
+		http.Error(w, "song not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		http.Error(w, "song version does not match If-Match", http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, repository.ErrSongExists) {
+		h.writeConflict(w, r, song.GroupName, song.Song)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		http.Error(w, groupNotFoundMessage, http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, repository.ErrGroupMismatch) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update song: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", songETag(&song, format))
+	writeSong(w, format, http.StatusOK, &song)
+}
+
+func applyPatch(song *database.Song, req PatchSongRequest) {
+	// Новое название группы выбирает группу по имени, а не прежний groupId
+	if req.GroupName != nil {
+		song.GroupName = strings.TrimSpace(*req.GroupName)
+		song.GroupID = 0
+	}
+	if req.GroupID != nil {
+		song.GroupID = *req.GroupID
+		// Без нового названия прежнее название заменится именем группы
+		if req.GroupName == nil && song.GroupID != 0 {
+			song.GroupName = ""
+		}
+	}
+	if req.Song != nil {
+		song.Song = strings.TrimSpace(*req.Song)
+	}
+	if req.ReleaseDate != nil {
+		song.SetRelease(*req.ReleaseDate)
+	}
+	if req.SongText != nil {
+		song.SongText = sql.NullString{String: *req.SongText, Valid: *req.SongText != ""}
+	}
+	if req.Link != nil {
+		song.Link = sql.NullString{String: *req.Link, Valid: *req.Link != ""}
+	}
+}
+
 // Удалить песню
 // @Summary Delete a song
-// @Description Deletes a song by its ID.
-// @Param id query int true "Song ID"
+// @Description Moves a song to the trash. It disappears from all reads, can be restored with
+// @Description POST /songs/{id}/restore and is deleted permanently after the trash retention period.
+// @Param id path int true "Song ID"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
 // @Success 204 {string} No Content
 // @Failure 400 {string} Invalid song ID
+// @Failure 404 {string} Song not found
+// @Failure 412 {string} Song version does not match If-Match
 // @Failure 500 {string} Failed to delete song
+// @Failure 403 {string} Requires the admin role
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
 // @Router /songs/{id} [delete]
 func (h *SongHandler) DeleteSong(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := songID(r)
 	if err != nil {
 		http.Error(w, "invalid song ID", http.StatusBadRequest)
 		return
 	}
 
-	err = h.Repo.DeleteSong(r.Context(), int32(id))
+	expected, err := h.expectedVersion(r, id)
+	if err == nil {
+		err = h.Repo.DeleteSong(r.Context(), id, expected)
+	}
+	if errors.Is(err, repository.ErrSongNotFound) {
+		http.Error(w, "song not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		http.Error(w, "song version does not match If-Match", http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to delete song: "+err.Error(), http.StatusInternalServerError)
 		return
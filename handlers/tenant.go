@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+// TenantHandler управляет клиентами сервиса.
+type TenantHandler struct {
+	Tenants tenant.Store
+	Repo    repository.Repository
+}
+
+func NewTenantHandler(tenants tenant.Store, repo repository.Repository) *TenantHandler {
+	return &TenantHandler{Tenants: tenants, Repo: repo}
+}
+
+// CreateTenantRequest — данные нового клиента.
+type CreateTenantRequest struct {
+	Slug string `json:"slug" example:"acme"`
+	Name string `json:"name" example:"Acme Records"`
+	// Квота на число песен; без нее число песен не ограничено
+	MaxSongs *int32 `json:"maxSongs,omitempty" example:"10000"`
+}
+
+// TenantInfo — клиент и число песен в его библиотеке.
+type TenantInfo struct {
+	tenant.Tenant
+	Songs int64 `json:"songs" example:"42"`
+}
+
+// Создать клиента
+// @Summary Create a tenant
+// @Description Creates a tenant with its own song library. The slug selects the tenant in the X-Tenant header,
+// @Description API keys and JWT claims. maxSongs limits the number of songs; omit it for no limit.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param data body CreateTenantRequest true "Tenant"
+// @Success 201 {object} tenant.Tenant
+// @Failure 400 {string} Invalid request
+// @Failure 403 {string} Requires the admin role not bound to a tenant
+// @Failure 409 {string} Tenant already exists
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /admin/tenants [post]
+func (h *TenantHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	if !allowTenantAdmin(w, r) {
+		return
+	}
+
+	var req CreateTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "Invalid request")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	switch {
+	case !tenant.ValidSlug(req.Slug):
+		http.Error(w, "slug must be 1-63 lowercase letters, digits or dashes, starting with a letter or digit", http.StatusBadRequest)
+		return
+	case req.Name == "":
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	case req.MaxSongs != nil && *req.MaxSongs < 0:
+		http.Error(w, "maxSongs must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	created, err := h.Tenants.Create(r.Context(), tenant.Tenant{Slug: req.Slug, Name: req.Name, MaxSongs: req.MaxSongs})
+	if errors.Is(err, tenant.ErrExists) {
+		http.Error(w, "tenant already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to create tenant", "tenant", req.Slug, "error", err)
+		http.Error(w, "failed to create tenant", http.StatusInternalServerError)
+		return
+	}
+	logger.InfoContext(r.Context(), "tenant created", "tenant", created.Slug, "tenant_id", created.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Список клиентов
+// @Summary List tenants
+// @Description Lists all tenants with the number of songs in each library.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} TenantInfo
+// @Failure 403 {string} Requires the admin role not bound to a tenant
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /admin/tenants [get]
+func (h *TenantHandler) ListTenants(w http.ResponseWriter, r *http.Request) {
+	if !allowTenantAdmin(w, r) {
+		return
+	}
+
+	tenants, err := h.Tenants.List(r.Context())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to list tenants", "error", err)
+		http.Error(w, "failed to list tenants", http.StatusInternalServerError)
+		return
+	}
+	infos := make([]TenantInfo, 0, len(tenants))
+	for _, t := range tenants {
+		songs, err := h.Repo.CountSongs(tenant.WithTenant(r.Context(), &t))
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to count songs", "tenant", t.Slug, "error", err)
+			http.Error(w, "failed to list tenants", http.StatusInternalServerError)
+			return
+		}
+		infos = append(infos, TenantInfo{Tenant: t, Songs: songs})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// allowTenantAdmin отклоняет учетные данные, привязанные к клиенту: admin
+// клиента управляет только своей библиотекой, но не другими клиентами и не
+// общими для всех клиентов данными вроде журнала отказов.
+func allowTenantAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if id := auth.FromContext(r.Context()); id != nil && id.Tenant != "" {
+		http.Error(w, "tenant-bound credentials cannot access cross-tenant admin endpoints", http.StatusForbidden)
+		return false
+	}
+	return true
+}
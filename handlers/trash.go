@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// TrashedSong — песня в корзине со сроком окончательного удаления.
+type TrashedSong struct {
+	Song      database.Song `json:"song"`
+	DeletedAt time.Time     `json:"deletedAt"`
+	// PurgeAt — когда песня будет удалена окончательно
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// Корзина
+// @Summary List deleted songs
+// @Description Songs deleted with DELETE /songs/{id}, most recently deleted first. A song stays
+// @Description in the trash until purgeAt, then it is deleted permanently; its history is kept.
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Produce text/csv
+// @Success 200 {array} TrashedSong
+// @Failure 406 {string} Not Acceptable
+// @Failure 500 {string} Failed to fetch the trash
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /trash [get]
+func (h *SongHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, true)
+	if !ok {
+		return
+	}
+	songs, err := h.Repo.ListDeleted(r.Context())
+	if err != nil {
+		http.Error(w, "failed to fetch the trash", http.StatusInternalServerError)
+		return
+	}
+	trashed := make([]TrashedSong, 0, len(songs))
+	for _, song := range songs {
+		trashed = append(trashed, TrashedSong{
+			Song:      *song,
+			DeletedAt: song.DeletedAt.Time,
+			PurgeAt:   song.DeletedAt.Time.Add(h.TrashRetention),
+		})
+	}
+	writeTrash(w, format, trashed)
+}
+
+// writeTrash отправляет содержимое корзины в выбранном формате. В XML, YAML
+// и CSV к полям песни добавляются deletedAt и purgeAt.
+func writeTrash(w http.ResponseWriter, format responseFormat, trashed []TrashedSong) {
+	w.Header().Set("Content-Type", format.contentType())
+	w.WriteHeader(http.StatusOK)
+	switch format {
+	case formatXML, formatYAML:
+		docs := make([]songDocument, len(trashed))
+		for i, t := range trashed {
+			docs[i] = newSongDocument(&t.Song)
+			docs[i].DeletedAt = t.DeletedAt.Format(time.RFC3339)
+			docs[i].PurgeAt = t.PurgeAt.Format(time.RFC3339)
+		}
+		if format == formatXML {
+			writeXML(w, songListDocument{Songs: docs})
+		} else {
+			yaml.NewEncoder(w).Encode(docs)
+		}
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(slices.Concat(songCSVHeader, []string{"deletedAt", "purgeAt"}))
+		for _, t := range trashed {
+			cw.Write(append(songCSVRecord(&t.Song), t.DeletedAt.Format(time.RFC3339), t.PurgeAt.Format(time.RFC3339)))
+		}
+		cw.Flush()
+	default:
+		json.NewEncoder(w).Encode(trashed)
+	}
+}
+
+// Вернуть песню из корзины
+// @Summary Restore a deleted song
+// @Description Moves a song from the trash back to the library with its old ID and records the change
+// @Description in the song history. Songs already purged from the trash can still be brought back
+// @Description with POST /songs/{id}/revisions/{rev}/revert.
+// @Produce json
+// @Produce xml
+// @Produce application/yaml
+// @Param id path int true "Song ID"
+// @Param Idempotency-Key header string false "Unique key; retries with the same key replay the first response"
+// @Success 200 {object} database.Song
+// @Header 200 {string} ETag "Song version"
+// @Failure 400 {string} Invalid song ID
+// @Failure 404 {string} Song not found in the trash
+// @Failure 409 {string} Another song with the same group and title exists
+// @Failure 500 {string} Failed to restore song
+// @Failure 406 {string} Not Acceptable
+// @Failure 403 {string} Requires the editor role, or the tenant song quota is exhausted
+// @Security BearerAuth
+// @Failure 401 {string} Missing or invalid API key
+// @Failure 429 {string} Rate limit exceeded, see Retry-After
+// @Router /songs/{id}/restore [post]
+func (h *SongHandler) RestoreSong(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(w, r, false)
+	if !ok {
+		return
+	}
+	id, err := songID(r)
+	if err != nil {
+		http.Error(w, "invalid song ID", http.StatusBadRequest)
+		return
+	}
+
+	song, err := h.Repo.UndeleteSong(r.Context(), id)
+	switch {
+	case errors.Is(err, repository.ErrSongNotFound):
+		http.Error(w, "song not found in the trash", http.StatusNotFound)
+	case errors.Is(err, repository.ErrSongExists):
+		http.Error(w, "another song with the same group and title exists", http.StatusConflict)
+	case errors.Is(err, repository.ErrQuotaExceeded):
+		http.Error(w, quotaExceededMessage, http.StatusForbidden)
+	case err != nil:
+		http.Error(w, "failed to restore song: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("ETag", songETag(song, format))
+		writeSong(w, format, http.StatusOK, song)
+	}
+}
@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// Тело запроса до этого размера хранится в памяти, больше — во временном файле.
+const memoryBodyLimit = 1 << 20
+
+// spoolBody дочитывает тело запроса, передавая его в w (хешу отпечатка), и
+// возвращает копию для обработчика. Большие тела, например на /import, не
+// держатся в памяти целиком. Копию нужно закрыть, чтобы удалить файл.
+func spoolBody(body io.Reader, w io.Writer) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	n, err := io.CopyN(io.MultiWriter(w, &buf), body, memoryBodyLimit+1)
+	if err == io.EOF || (err == nil && n <= memoryBodyLimit) {
+		return io.NopCloser(&buf), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "songgo-body-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled := &fileBody{File: f}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := io.Copy(io.MultiWriter(w, f), body); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// fileBody — тело запроса во временном файле, который удаляется при закрытии.
+type fileBody struct {
+	*os.File
+}
+
+func (b *fileBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.Name())
+	return err
+}
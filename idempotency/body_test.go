@@ -0,0 +1,53 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSpoolBodyKeepsSmallBodyInMemory(t *testing.T) {
+	var hashed bytes.Buffer
+	body, err := spoolBody(strings.NewReader("small"), &hashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+
+	if _, ok := body.(*fileBody); ok {
+		t.Fatal("small body was spooled to a file")
+	}
+	got, _ := io.ReadAll(body)
+	if string(got) != "small" || hashed.String() != "small" {
+		t.Fatalf("body = %q, hashed = %q, want %q", got, hashed.String(), "small")
+	}
+}
+
+func TestSpoolBodySpillsLargeBodyToFile(t *testing.T) {
+	data := strings.Repeat("0123456789", memoryBodyLimit/5)
+	h := sha256.New()
+	body, err := spoolBody(strings.NewReader(data), h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := body.(*fileBody)
+	if !ok {
+		t.Fatal("large body was kept in memory")
+	}
+	got, _ := io.ReadAll(body)
+	if string(got) != data {
+		t.Fatalf("body has %d bytes, want %d", len(got), len(data))
+	}
+	if want := sha256.Sum256([]byte(data)); !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Fatal("hash does not cover the whole body")
+	}
+
+	body.Close()
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("temp file %s was not removed: %v", f.Name(), err)
+	}
+}
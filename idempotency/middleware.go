@@ -0,0 +1,228 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+// HeaderName — заголовок, в котором клиент передает ключ идемпотентности.
+const HeaderName = "Idempotency-Key"
+
+// Максимальная длина ключа идемпотентности.
+const maxKeyLength = 255
+
+// reservationLease — на сколько закрепляется ключ выполняющегося запроса.
+// Пока обработчик работает, закрепление продлевается; если процесс упал, ключ
+// освобождается через reservationLease, а не через ttl.
+const reservationLease = time.Minute
+
+// Middleware сохраняет первый ответ на мутирующий запрос (POST, PUT, PATCH, DELETE)
+// с заголовком Idempotency-Key и воспроизводит его для повторов в течение ttl.
+// Ключи действуют в пределах клиента (tenant) и учетных данных: разные
+// API-ключи и JWT одного клиента не видят ответов друг друга. Подключается
+// после проверки роли, чтобы повтор не обходил ее.
+// Повтор ключа с другим методом, путем или телом отклоняется с 422, повтор во
+// время выполнения первого запроса — с 409.
+// Ответы 5xx, 401 и 403 не сохраняются, чтобы клиент мог повторить запрос
+// после исправления учетных данных или сбоя.
+func Middleware(store Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderName)
+			if key == "" || !isMutating(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+			// У каждого клиента и учетных данных свое пространство ключей
+			key = strconv.Itoa(int(tenant.ID(r.Context()))) + ":" + identityKey(r) + ":" + key
+
+			h := requestHasher(r)
+			body, err := spoolBody(r.Body, h)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			defer body.Close()
+			r.Body = body
+			hash := hex.EncodeToString(h.Sum(nil))
+
+			rec, reserved, err := store.Reserve(r.Context(), key, hash, reservationLease)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to reserve idempotency key", "error", err)
+				http.Error(w, "failed to process Idempotency-Key", http.StatusInternalServerError)
+				return
+			}
+			if !reserved {
+				replay(w, rec, hash)
+				return
+			}
+
+			// Ответ сохраняем даже если клиент уже отключился
+			ctx := context.WithoutCancel(r.Context())
+			rw := &responseRecorder{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := store.Release(ctx, key); err != nil {
+					logger.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}()
+
+			stopRenewal := renewReservation(ctx, store, key)
+			next.ServeHTTP(rw, r)
+			stopRenewal()
+
+			status := rw.statusCode()
+			if !storable(status) {
+				return
+			}
+			resp := Response{StatusCode: status, Header: rw.Header().Clone(), Body: rw.body.Bytes()}
+			if err := store.Complete(ctx, key, resp, ttl); err != nil {
+				logger.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// renewReservation продлевает закрепление ключа, пока выполняется запрос.
+// Возвращает функцию, которая останавливает продление.
+func renewReservation(ctx context.Context, store Store, key string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(reservationLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := store.Extend(ctx, key, reservationLease); err != nil {
+					logger.ErrorContext(ctx, "failed to extend idempotency key reservation", "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// identityKey возвращает учетные данные запроса; без аутентификации все
+// запросы клиента делят одно пространство ключей.
+func identityKey(r *http.Request) string {
+	if id := auth.FromContext(r.Context()); id != nil {
+		return id.Key()
+	}
+	return "anonymous"
+}
+
+// storable сообщает, сохранять ли ответ с этим кодом для повторов.
+func storable(status int) bool {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return false
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return false
+	}
+	return true
+}
+
+// replay отвечает на повтор запроса с уже использованным ключом.
+func replay(w http.ResponseWriter, rec *Record, hash string) {
+	if rec.RequestHash != hash {
+		http.Error(w, "Idempotency-Key was already used with a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if rec.Response == nil {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	}
+
+	for name, values := range rec.Response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Response.StatusCode)
+	w.Write(rec.Response.Body)
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestHasher начинает отпечаток запроса с метода и пути с параметрами;
+// тело дописывается в хеш по мере чтения.
+func requestHasher(r *http.Request) hash.Hash {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.RequestURI())
+	h.Write([]byte{0})
+	return h
+}
+
+// responseRecorder передает ответ клиенту и одновременно запоминает его.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного ResponseWriter.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *responseRecorder) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
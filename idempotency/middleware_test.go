@@ -0,0 +1,192 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+)
+
+// countingHandler отвечает 201 с телом и считает вызовы.
+func countingHandler(calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+}
+
+func newRequest(key, body string, id *auth.Identity) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/songs", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(HeaderName, key)
+	}
+	if id != nil {
+		r = r.WithContext(auth.WithIdentity(r.Context(), id))
+	}
+	return r
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddlewareReplaysFirstResponse(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(), time.Hour)(countingHandler(&calls))
+
+	first := serve(h, newRequest("k1", `{"song":"a"}`, nil))
+	second := serve(h, newRequest("k1", `{"song":"a"}`, nil))
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("replay is missing Idempotent-Replayed header")
+	}
+}
+
+func TestMiddlewareRejectsKeyReuseWithDifferentBody(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(), time.Hour)(countingHandler(&calls))
+
+	serve(h, newRequest("k1", `{"song":"a"}`, nil))
+	w := serve(h, newRequest("k1", `{"song":"b"}`, nil))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+}
+
+func TestMiddlewareRejectsConcurrentRetry(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	h := Middleware(NewMemoryStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serve(h, newRequest("k1", `{}`, nil))
+	}()
+	<-started
+
+	w := serve(h, newRequest("k1", `{}`, nil))
+	close(release)
+	<-done
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("409 is missing Retry-After")
+	}
+}
+
+func TestMiddlewareScopesKeysByCredentials(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(), time.Hour)(countingHandler(&calls))
+
+	serve(h, newRequest("k1", `{}`, &auth.Identity{Method: auth.MethodAPIKey, KeyID: 1}))
+	w := serve(h, newRequest("k1", `{}`, &auth.Identity{Method: auth.MethodAPIKey, KeyID: 2}))
+
+	if calls.Load() != 2 {
+		t.Fatalf("handler called %d times, want 2: keys of different credentials must not collide", calls.Load())
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("response of another key was replayed")
+	}
+}
+
+func TestMiddlewareDoesNotStoreFailures(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusUnauthorized, http.StatusForbidden} {
+		var calls atomic.Int32
+		h := Middleware(NewMemoryStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		serve(h, newRequest("k1", `{}`, nil))
+		serve(h, newRequest("k1", `{}`, nil))
+
+		if calls.Load() != 2 {
+			t.Errorf("status %d: handler called %d times, want 2", status, calls.Load())
+		}
+	}
+}
+
+func TestMiddlewareIgnoresRequestsWithoutKey(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(NewMemoryStore(), time.Hour)(countingHandler(&calls))
+
+	serve(h, newRequest("", `{}`, nil))
+	serve(h, newRequest("", `{}`, nil))
+
+	if calls.Load() != 2 {
+		t.Fatalf("handler called %d times, want 2", calls.Load())
+	}
+}
+
+func TestMiddlewarePassesLargeBodyToHandler(t *testing.T) {
+	body := strings.Repeat("x", memoryBodyLimit*2+17)
+	var got string
+	h := Middleware(NewMemoryStore(), time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		got = buf.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve(h, newRequest("k1", body, nil))
+
+	if got != body {
+		t.Fatalf("handler read %d bytes, want %d", len(got), len(body))
+	}
+}
+
+func TestMemoryStoreReservationLeaseExpires(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if _, reserved, _ := store.Reserve(ctx, "k", "h", 10*time.Millisecond); !reserved {
+		t.Fatal("first Reserve did not reserve the key")
+	}
+	if _, reserved, _ := store.Reserve(ctx, "k", "h", 10*time.Millisecond); reserved {
+		t.Fatal("key reserved twice within the lease")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, reserved, _ := store.Reserve(ctx, "k", "h", time.Minute); !reserved {
+		t.Fatal("abandoned reservation was not taken over after the lease")
+	}
+}
+
+func TestMemoryStoreKeepsCompletedResponseForTTL(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	store.Reserve(ctx, "k", "h", 10*time.Millisecond)
+	store.Complete(ctx, "k", Response{StatusCode: http.StatusCreated}, time.Hour)
+	time.Sleep(20 * time.Millisecond)
+
+	rec, reserved, _ := store.Reserve(ctx, "k", "h", time.Minute)
+	if reserved || rec == nil || rec.Response == nil || rec.Response.StatusCode != http.StatusCreated {
+		t.Fatalf("Reserve after the lease = %+v, %v; want the stored response", rec, reserved)
+	}
+}
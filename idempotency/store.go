@@ -0,0 +1,219 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+)
+
+var logger = logging.For(logging.ComponentIdempotency)
+
+// Response — сохраненный ответ на первый запрос с данным ключом.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Record — состояние ключа идемпотентности. Response равен nil,
+// пока первый запрос еще выполняется.
+type Record struct {
+	RequestHash string
+	Response    *Response
+}
+
+// Store хранит ключи идемпотентности и ответы на них.
+type Store interface {
+	// Reserve закрепляет ключ за запросом с отпечатком requestHash на время
+	// lease. Если ключ уже занят и не истек, возвращает существующую запись и
+	// false. Закрепление, которое никто не продлил, например после падения
+	// процесса, по истечении lease может занять следующий запрос.
+	Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*Record, bool, error)
+	// Extend продлевает закрепление ключа, запрос по которому еще выполняется.
+	Extend(ctx context.Context, key string, lease time.Duration) error
+	// Complete сохраняет ответ для закрепленного ключа на время ttl.
+	Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error
+	// Release освобождает ключ, ответ на который не был сохранен.
+	Release(ctx context.Context, key string) error
+}
+
+// Как часто MemoryStore удаляет истекшие ключи.
+const memorySweepInterval = time.Minute
+
+// MemoryStore хранит ключи в памяти процесса.
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*memoryRecord
+	lastSweep time.Time
+}
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord)}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Истекшие ключи удаляем не чаще раза в memorySweepInterval, а не на каждый запрос
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= memorySweepInterval {
+		for k, rec := range s.records {
+			if now.After(rec.expiresAt) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if rec, exists := s.records[key]; exists && !now.After(rec.expiresAt) {
+		existing := rec.Record
+		return &existing, false, nil
+	}
+	s.records[key] = &memoryRecord{
+		Record:    Record{RequestHash: requestHash},
+		expiresAt: now.Add(lease),
+	}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Extend(ctx context.Context, key string, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, exists := s.records[key]; exists && rec.Response == nil {
+		rec.expiresAt = time.Now().Add(lease)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, exists := s.records[key]; exists {
+		rec.Response = &resp
+		rec.expiresAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, exists := s.records[key]; exists && rec.Response == nil {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// PostgresStore хранит ключи в таблице idempotency_keys, чтобы повторы
+// распознавались всеми экземплярами сервиса.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{queries: database.New(db)}
+}
+
+func (s *PostgresStore) Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*Record, bool, error) {
+	reserved, err := s.queries.ReserveIdempotencyKey(ctx, database.ReserveIdempotencyKeyParams{
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   time.Now().Add(lease),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved > 0 {
+		return nil, true, nil
+	}
+
+	row, err := s.queries.GetIdempotencyKey(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Ключ успели освободить между запросами — пробуем еще раз
+		return s.Reserve(ctx, key, requestHash, lease)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	rec := &Record{RequestHash: row.RequestHash}
+	if row.StatusCode.Valid {
+		header := http.Header{}
+		if err := json.Unmarshal(row.ResponseHeaders, &header); err != nil {
+			return nil, false, err
+		}
+		rec.Response = &Response{
+			StatusCode: int(row.StatusCode.Int32),
+			Header:     header,
+			Body:       row.ResponseBody,
+		}
+	}
+	return rec, false, nil
+}
+
+func (s *PostgresStore) Extend(ctx context.Context, key string, lease time.Duration) error {
+	return s.queries.ExtendIdempotencyKey(ctx, database.ExtendIdempotencyKeyParams{
+		Key:       key,
+		ExpiresAt: time.Now().Add(lease),
+	})
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, resp Response, ttl time.Duration) error {
+	header, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+	body := resp.Body
+	if body == nil {
+		body = []byte{}
+	}
+	return s.queries.CompleteIdempotencyKey(ctx, database.CompleteIdempotencyKeyParams{
+		Key:             key,
+		StatusCode:      sql.NullInt32{Int32: int32(resp.StatusCode), Valid: true},
+		ResponseHeaders: header,
+		ResponseBody:    body,
+		ExpiresAt:       time.Now().Add(ttl),
+	})
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	return s.queries.ReleaseIdempotencyKey(ctx, key)
+}
+
+// RunCleanup периодически удаляет истекшие ключи, пока не отменен ctx.
+func (s *PostgresStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.queries.DeleteExpiredIdempotencyKeys(ctx)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.InfoContext(ctx, "deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
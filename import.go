@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kitrop/songGO-lib/importer"
+	"github.com/Kitrop/songGO-lib/musicapi"
+	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+// runImport загружает песни из файла напрямую в базу данных:
+//
+//	songgo import [-format csv|ndjson] [-columns group=Artist,...] [-delimiter ;] [-enrich] [-report report.csv] [-tenant default] file
+//
+// Вместо имени файла можно передать "-", чтобы читать из stdin.
+// Возвращает код завершения: 1, если хотя бы одна строка не загружена.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	formatName := fs.String("format", "", "input format: csv or ndjson (default: by file extension)")
+	columnsSpec := fs.String("columns", "", "CSV column mapping, e.g. group=Artist,song=Title")
+	delimiter := fs.String("delimiter", ",", "CSV delimiter")
+	enrich := fs.Bool("enrich", false, "fill missing release date, text and link from the external API")
+	reportPath := fs.String("report", "", "write the per-row report to this file (.csv or .json)")
+	tenantSlug := fs.String("tenant", tenant.DefaultSlug, "tenant whose library receives the songs")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: songgo import [flags] file|-")
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, err := importer.ParseFormat(*formatName)
+	if err != nil {
+		fatal("invalid format", "error", err)
+	}
+	columns, err := importer.ParseColumns(*columnsSpec)
+	if err != nil {
+		fatal("invalid column mapping", "error", err)
+	}
+	comma, size := utf8.DecodeRuneInString(*delimiter)
+	if size == 0 || size != len(*delimiter) {
+		fatal("delimiter must be a single character", "delimiter", *delimiter)
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fatal("failed to open the file", "error", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, err = tenantContext(ctx, db, *tenantSlug)
+	if err != nil {
+		cliLogger.Error("failed to resolve the tenant", "error", err)
+		return 1
+	}
+
+	imp := importer.New(repository.NewPostgresRepository(db), musicapi.NewClient(cfg.ExternalAPIPath, time.Duration(cfg.ExternalAPITimeout)))
+	report, err := imp.Import(ctx, in, importer.Options{
+		Format:    format,
+		Columns:   columns,
+		Delimiter: comma,
+		Enrich:    *enrich,
+	})
+	if report == nil {
+		cliLogger.Error("import failed", "error", err)
+		return 1
+	}
+	if err != nil {
+		cliLogger.Error("import aborted", "error", err)
+	}
+
+	fmt.Printf("created: %d, skipped: %d, failed: %d\n", report.Created, report.Skipped, report.Failed)
+	if *reportPath != "" {
+		if err := writeReport(*reportPath, report); err != nil {
+			cliLogger.Error("failed to write the report", "path", *reportPath, "error", err)
+			return 1
+		}
+	}
+	if err != nil || report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// writeReport сохраняет отчет в CSV или JSON в зависимости от расширения файла.
+func writeReport(path string, report *importer.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return report.WriteCSV(f)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
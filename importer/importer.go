@@ -0,0 +1,232 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/musicapi"
+	"github.com/Kitrop/songGO-lib/repository"
+)
+
+// Format — формат входных данных импорта.
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Поля песни, которые можно сопоставить колонкам CSV.
+const (
+	FieldGroup       = "group"
+	FieldSong        = "song"
+	FieldReleaseDate = "releaseDate"
+	FieldText        = "text"
+	FieldLink        = "link"
+)
+
+var fields = []string{FieldGroup, FieldSong, FieldReleaseDate, FieldText, FieldLink}
+
+// Options — настройки импорта.
+type Options struct {
+	Format Format
+	// Columns сопоставляет полям песни заголовки колонок CSV. Для полей без
+	// сопоставления используется заголовок, совпадающий с именем поля.
+	Columns map[string]string
+	// Delimiter — разделитель полей CSV, по умолчанию запятая.
+	Delimiter rune
+	// Enrich дополняет отсутствующие дату, текст и ссылку данными внешнего API.
+	Enrich bool
+	// MaxReportRows ограничивает число построчных результатов в отчете;
+	// 0 — без ограничения. Счетчики учитывают все строки.
+	MaxReportRows int
+}
+
+// ParseFormat разбирает имя формата ("csv", "ndjson", "jsonl").
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported import format %q", s)
+}
+
+// ParseColumns разбирает сопоставление колонок вида "group=Artist,song=Title".
+func ParseColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field, column = strings.TrimSpace(field), strings.TrimSpace(column)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=Column", pair)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(fields, ", "))
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+func isField(name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// record — одна строка входных данных.
+type record struct {
+	Group       string
+	Song        string
+	ReleaseDate string
+	Text        string
+	Link        string
+}
+
+// rowReader читает входные данные построчно. Ошибка разбора отдельной строки
+// возвращается как rowError, после нее чтение можно продолжить.
+type rowReader interface {
+	Next() (line int, rec record, err error)
+}
+
+type rowError struct {
+	err error
+}
+
+func (e rowError) Error() string { return e.err.Error() }
+
+// Importer загружает песни из CSV и NDJSON.
+type Importer struct {
+	Repo     repository.Repository
+	MusicAPI *musicapi.Client
+}
+
+func New(repo repository.Repository, api *musicapi.Client) *Importer {
+	return &Importer{Repo: repo, MusicAPI: api}
+}
+
+// Import читает песни из in построчно, проверяет и сохраняет каждую строку.
+// Уже существующие песни пропускаются. Ошибка возвращается, только если
+// продолжать чтение невозможно; ошибки отдельных строк попадают в отчет.
+func (im *Importer) Import(ctx context.Context, in io.Reader, opts Options) (*Report, error) {
+	var rows rowReader
+	var err error
+	switch opts.Format {
+	case FormatCSV:
+		rows, err = newCSVReader(in, opts)
+	case FormatNDJSON:
+		rows = newNDJSONReader(in)
+	default:
+		err = fmt.Errorf("unsupported import format %q", opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Format: opts.Format, StartedAt: time.Now(), maxRows: opts.MaxReportRows}
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		line, rec, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr rowError
+		if errors.As(err, &rowErr) {
+			report.add(RowResult{Line: line, Status: StatusFailed, Message: rowErr.Error()})
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.add(im.importRow(ctx, line, rec, opts))
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+// importRow проверяет и сохраняет одну строку.
+func (im *Importer) importRow(ctx context.Context, line int, rec record, opts Options) RowResult {
+	result := RowResult{
+		Line:  line,
+		Group: strings.TrimSpace(rec.Group),
+		Song:  strings.TrimSpace(rec.Song),
+	}
+	if result.Group == "" || result.Song == "" {
+		result.Status = StatusFailed
+		result.Message = "group and song are required"
+		return result
+	}
+
+	if existing, err := im.Repo.GetSongByName(ctx, result.Group, result.Song); err == nil {
+		result.Status = StatusSkipped
+		result.ID = existing.ID
+		result.Message = "song already exists"
+		return result
+	}
+
+	releaseDate, err := database.ParseReleaseDate(rec.ReleaseDate)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Message = err.Error()
+		return result
+	}
+
+	if opts.Enrich && (!releaseDate.Valid || rec.Text == "" || rec.Link == "") {
+		info, err := im.MusicAPI.SongInfo(ctx, result.Group, result.Song)
+		if err != nil {
+			result.Message = "not enriched: " + err.Error()
+		} else {
+			if !releaseDate.Valid {
+				if parsed, err := database.ParseReleaseDate(info.ReleaseDate); err == nil {
+					releaseDate = parsed
+				}
+			}
+			if rec.Text == "" {
+				rec.Text = info.Text
+			}
+			if rec.Link == "" {
+				rec.Link = info.Link
+			}
+		}
+	}
+
+	song := &database.Song{
+		GroupName: result.Group,
+		Song:      result.Song,
+		SongText:  sql.NullString{String: rec.Text, Valid: rec.Text != ""},
+		Link:      sql.NullString{String: rec.Link, Valid: rec.Link != ""},
+	}
+	song.SetRelease(releaseDate)
+
+	_, err = im.Repo.CreateSong(ctx, song)
+	switch {
+	case errors.Is(err, repository.ErrSongExists):
+		result.Status = StatusSkipped
+		result.Message = "song already exists"
+	case err != nil:
+		result.Status = StatusFailed
+		result.Message = err.Error()
+	default:
+		result.Status = StatusCreated
+		result.ID = song.ID
+	}
+	return result
+}
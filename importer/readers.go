@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Максимальная длина строки NDJSON (тексты песен бывают длинными).
+const maxLineSize = 4 << 20
+
+// csvReader читает CSV с заголовком в первой строке.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(in io.Reader, opts Options) (*csvReader, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+
+	columns := make(map[string]int)
+	for _, field := range fields {
+		name := field
+		if mapped, ok := opts.Columns[field]; ok {
+			name = mapped
+		}
+		if i, ok := index[strings.ToLower(name)]; ok {
+			columns[field] = i
+		} else if _, mapped := opts.Columns[field]; mapped {
+			return nil, fmt.Errorf("column %q for field %s not found in CSV header", name, field)
+		}
+	}
+	if _, ok := columns[FieldGroup]; !ok {
+		return nil, fmt.Errorf("CSV header has no column for field %s", FieldGroup)
+	}
+	if _, ok := columns[FieldSong]; !ok {
+		return nil, fmt.Errorf("CSV header has no column for field %s", FieldSong)
+	}
+
+	return &csvReader{r: r, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (int, record, error) {
+	row, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, record{}, rowError{err}
+	}
+	if err != nil {
+		return 0, record{}, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	get := func(field string) string {
+		if i, ok := c.columns[field]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	return line, record{
+		Group:       get(FieldGroup),
+		Song:        get(FieldSong),
+		ReleaseDate: get(FieldReleaseDate),
+		Text:        get(FieldText),
+		Link:        get(FieldLink),
+	}, nil
+}
+
+// ndjsonReader читает по одному JSON-объекту на строку.
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// ndjsonRecord принимает и короткие имена полей, и имена из ответа API.
+type ndjsonRecord struct {
+	Group       string `json:"group"`
+	GroupName   string `json:"groupName"`
+	Song        string `json:"song"`
+	ReleaseDate string `json:"releaseDate"`
+	Text        string `json:"text"`
+	SongText    string `json:"songText"`
+	Link        string `json:"link"`
+}
+
+func newNDJSONReader(in io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (n *ndjsonReader) Next() (int, record, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := strings.TrimSpace(n.scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var rec ndjsonRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return n.line, record{}, rowError{fmt.Errorf("invalid JSON: %w", err)}
+		}
+		return n.line, record{
+			Group:       firstNonEmpty(rec.Group, rec.GroupName),
+			Song:        rec.Song,
+			ReleaseDate: rec.ReleaseDate,
+			Text:        firstNonEmpty(rec.Text, rec.SongText),
+			Link:        rec.Link,
+		}, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return n.line + 1, record{}, err
+	}
+	return n.line, record{}, io.EOF
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
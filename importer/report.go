@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// RowStatus — итог обработки строки.
+type RowStatus string
+
+const (
+	StatusCreated RowStatus = "created"
+	StatusSkipped RowStatus = "skipped"
+	StatusFailed  RowStatus = "failed"
+)
+
+// RowResult — результат импорта одной строки.
+type RowResult struct {
+	Line    int       `json:"line" example:"2"`
+	Status  RowStatus `json:"status" enums:"created,skipped,failed" example:"created"`
+	Group   string    `json:"group,omitempty" example:"Muse"`
+	Song    string    `json:"song,omitempty" example:"Supermassive Black Hole"`
+	ID      int32     `json:"id,omitempty" example:"1"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Report — отчет об импорте.
+type Report struct {
+	ID         string      `json:"id" example:"5f0c6a1e9b2d4c7f"`
+	Format     Format      `json:"format" example:"csv"`
+	StartedAt  time.Time   `json:"startedAt"`
+	FinishedAt time.Time   `json:"finishedAt"`
+	Created    int         `json:"created" example:"10"`
+	Skipped    int         `json:"skipped" example:"2"`
+	Failed     int         `json:"failed" example:"1"`
+	Rows       []RowResult `json:"rows,omitempty"`
+	// RowsTruncated — в Rows попали не все строки из-за Options.MaxReportRows.
+	RowsTruncated bool `json:"rowsTruncated,omitempty" example:"false"`
+
+	maxRows int
+}
+
+func (r *Report) add(row RowResult) {
+	switch row.Status {
+	case StatusCreated:
+		r.Created++
+	case StatusSkipped:
+		r.Skipped++
+	case StatusFailed:
+		r.Failed++
+	}
+	if r.maxRows > 0 && len(r.Rows) >= r.maxRows {
+		r.RowsTruncated = true
+		return
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// Summary возвращает копию отчета без построчных результатов.
+func (r *Report) Summary() *Report {
+	summary := *r
+	summary.Rows = nil
+	return &summary
+}
+
+// WriteCSV записывает построчные результаты отчета в CSV.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"line", "status", "group", "song", "id", "message"})
+	for _, row := range r.Rows {
+		id := ""
+		if row.ID != 0 {
+			id = strconv.Itoa(int(row.ID))
+		}
+		cw.Write([]string{strconv.Itoa(row.Line), string(row.Status), row.Group, row.Song, id, row.Message})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,132 @@
+// Package logging настраивает структурированные логи сервиса: JSON через
+// log/slog, отдельный уровень для каждого компонента и ID запроса, который
+// берется из контекста и добавляется к каждой записи.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Компоненты, для которых можно задать свой уровень логирования.
+const (
+	ComponentServer      = "server"
+	ComponentHTTP        = "http"
+	ComponentHandlers    = "handlers"
+	ComponentRepository  = "repository"
+	ComponentMusicAPI    = "musicapi"
+	ComponentIdempotency = "idempotency"
+	ComponentAuth        = "auth"
+	ComponentAudit       = "audit"
+	ComponentRateLimit   = "ratelimit"
+	ComponentTenant      = "tenant"
+	ComponentMetrics     = "metrics"
+	ComponentMigrate     = "migrate"
+	ComponentCLI         = "cli"
+)
+
+// Components — все известные компоненты.
+var Components = []string{
+	ComponentServer, ComponentHTTP, ComponentHandlers, ComponentRepository, ComponentMusicAPI,
+	ComponentIdempotency, ComponentAuth, ComponentAudit, ComponentRateLimit, ComponentTenant, ComponentMetrics,
+	ComponentMigrate, ComponentCLI,
+}
+
+var (
+	// Записи фильтруются уровнями компонентов, поэтому сам вывод пропускает все
+	output = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+
+	mu           sync.Mutex
+	defaultLevel = slog.LevelInfo
+	levels       = map[string]*slog.LevelVar{}
+)
+
+// Setup задает уровень по умолчанию и уровни отдельных компонентов и делает
+// логгер компонента server логгером по умолчанию, чтобы записи пакета log
+// тоже выходили в JSON. Можно вызывать повторно: уже созданные логгеры
+// подхватывают новые уровни.
+func Setup(level slog.Level, componentLevels map[string]slog.Level) {
+	mu.Lock()
+	defaultLevel = level
+	for _, component := range Components {
+		levelVar(component)
+	}
+	for component, v := range levels {
+		if l, ok := componentLevels[component]; ok {
+			v.Set(l)
+		} else {
+			v.Set(level)
+		}
+	}
+	mu.Unlock()
+
+	slog.SetDefault(For(ComponentServer))
+}
+
+// levelVar возвращает уровень компонента, создавая его при первом обращении.
+// Вызывается под mu.
+func levelVar(component string) *slog.LevelVar {
+	v, ok := levels[component]
+	if !ok {
+		v = new(slog.LevelVar)
+		v.Set(defaultLevel)
+		levels[component] = v
+	}
+	return v
+}
+
+// For возвращает логгер компонента. Логгеры можно создавать при инициализации
+// пакета — уровень берется в момент записи.
+func For(component string) *slog.Logger {
+	mu.Lock()
+	level := levelVar(component)
+	mu.Unlock()
+	return slog.New(&handler{
+		Handler: output.WithAttrs([]slog.Attr{slog.String("component", component)}),
+		level:   level,
+	})
+}
+
+// ParseLevel разбирает уровень: debug, info, warn или error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// handler отбрасывает записи ниже уровня компонента и добавляет ID запроса
+// и, если запрос трассируется, ID трассировки и спана.
+type handler struct {
+	slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
@@ -2,14 +2,34 @@ package main
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/backup"
 	"github.com/Kitrop/songGO-lib/config"
 	"github.com/Kitrop/songGO-lib/handlers"
+	"github.com/Kitrop/songGO-lib/idempotency"
+	"github.com/Kitrop/songGO-lib/importer"
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/Kitrop/songGO-lib/metrics"
+	"github.com/Kitrop/songGO-lib/migrations"
+	"github.com/Kitrop/songGO-lib/musicapi"
+	"github.com/Kitrop/songGO-lib/ratelimit"
 	"github.com/Kitrop/songGO-lib/repository"
+	"github.com/Kitrop/songGO-lib/tenant"
+	"github.com/Kitrop/songGO-lib/tracing"
 	_ "github.com/Kitrop/songGO-lib/docs"
 
 	"github.com/go-chi/chi/middleware"
@@ -20,82 +40,614 @@ import (
 
 
 
+// version — версия сборки, задается при сборке:
+// go build -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 // @title Song API
 // @version 1.0
-// @description API for managing songs.
+// @description API for managing songs. Song responses are JSON by default; send Accept: application/xml,
+// @description application/yaml or (for lists) text/csv to get another format.
 // @host localhost:8080
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description API key or SSO-issued JWT as "Bearer <token>". Create a key with: songgo apikey create -name NAME
 func main() {
-	// Настройка логгера
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("[INFO] Запускаем приложение...")
+	// До загрузки конфигурации пишем логи с уровнем info
+	logging.Setup(slog.LevelInfo, nil)
+
+	// Подключаем env, если рядом есть файл .env
+	if err := config.LoadEnv(); err != nil {
+		fatal("failed to load .env", "error", err)
+	}
+
+	// Первый аргумент — подкоманда, по умолчанию запускается сервер
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(runServer(args))
+	case "import":
+		os.Exit(runImport(args))
+	case "backup":
+		os.Exit(runBackup(args))
+	case "restore":
+		os.Exit(runRestore(args))
+	case "migrate":
+		os.Exit(runMigrate(args))
+	case "apikey":
+		os.Exit(runAPIKey(args))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+}
+
+const usage = `Usage: songgo [command] [flags]
+
+Commands:
+  serve    run the HTTP API server (default)
+  import   import songs from a CSV or NDJSON file
+  backup   save all songs to a checksummed archive
+  restore  replace all songs with the contents of an archive
+  migrate  apply, roll back or inspect database migrations
+  apikey   create, list or revoke API keys
+`
+
+// loadConfig разбирает флаги подкоманды и загружает конфигурацию. При ошибке
+// выводит ее и завершает программу.
+func loadConfig(fs *flag.FlagSet, args []string) *config.Config {
+	flags := config.RegisterFlags(fs)
+	fs.Parse(args)
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logging.Setup(cfg.Logging())
+	return cfg
+}
 
-	// Подключаем env
-	config.LoadEnv()
+// cliLogger — логгер подкоманд import, backup и restore.
+var cliLogger = logging.For(logging.ComponentCLI)
 
+// fatal пишет ошибку в лог и завершает программу.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// openDB подключается к базе данных и применяет миграции, если автоматическая
+// миграция не отключена.
+func openDB(cfg *config.Config) *sql.DB {
+	if cfg.DatabasePath == "" {
+		fatal("database connection string is not set (DATABASE_PATH or -database)")
+	}
 
-	// Подключаем БД
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	
-	db, err := config.ConnectDB(ctx)
+
+	db, err := config.ConnectDB(ctx, cfg.DatabasePath)
 	if err != nil {
-		log.Fatalf("[ERROR] Ошибка подключения к базе данных: %v", err)
+		fatal("failed to connect to the database", "error", err)
 	}
 
-	// Закрытие подключения к БД
-	defer func() {
-		log.Println("[INFO] Закрытие соединения с базой данных....")
-		db.Close()
-	}()
+	if !cfg.AutoMigrate {
+		version, dirty, err := config.SchemaVersion(db)
+		if err != nil {
+			db.Close()
+			fatal("failed to read the schema version", "error", err)
+		}
+		if dirty {
+			db.Close()
+			fatal("database schema is dirty, use songgo migrate force", "schema_version", version)
+		}
+		slog.Info("auto-migration is disabled", "schema_version", version)
+		return db
+	}
 
 	// Выполняем миграции
 	if err := config.MigrateDB(db); err != nil {
-		log.Fatalf("[ERROR] Ошибка миграции базы данных: %v", err)
+		db.Close()
+		fatal("failed to migrate the database", "error", err)
+	}
+	return db
+}
+
+// tenantContext возвращает контекст с клиентом slug для команд CLI.
+func tenantContext(ctx context.Context, db *sql.DB, slug string) (context.Context, error) {
+	t, err := tenant.NewPostgresStore(db).GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("tenant %q: %w", slug, err)
+	}
+	return tenant.WithTenant(ctx, t), nil
+}
+
+// runServer запускает HTTP API и работает до SIGINT или SIGTERM. При остановке
+// сервер перестает принимать соединения, дожидается текущих запросов и фоновых
+// задач (не дольше shutdown_timeout) и закрывает базу данных.
+func runServer(args []string) int {
+	cfg := loadConfig(flag.NewFlagSet("serve", flag.ExitOnError), args)
+
+	slog.Info("starting", "version", buildVersion(), "storage", cfg.StorageBackend)
+
+	// Трассировка; по умолчанию спаны не экспортируются
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+		Version:     buildVersion(),
+	})
+	if err != nil {
+		fatal("failed to set up tracing", "error", err)
+	}
+
+	// Фоновые задачи останавливаются отменой workersCtx при завершении сервера
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+
+	// Создаем репозиторий и хранилище ключей идемпотентности
+	var repo repository.Repository
+	var idempotencyStore idempotency.Store
+	var authStore auth.Store
+	var auditStore auth.AuditStore
+	var tenantStore tenant.Store
+	var db *sql.DB
+	switch cfg.StorageBackend {
+	case config.BackendPostgres:
+		// Подключаем БД
+		db = openDB(cfg)
+
+		// Закрытие подключения к БД
+		defer func() {
+			slog.Info("closing the database connection")
+			db.Close()
+		}()
+
+		repo = repository.NewPostgresRepository(db)
+		pgStore := idempotency.NewPostgresStore(db)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			pgStore.RunCleanup(workersCtx, time.Hour)
+		}()
+		idempotencyStore = pgStore
+		authStore = auth.NewPostgresStore(db)
+		auditStore = auth.NewPostgresAuditStore(db)
+		tenantStore = tenant.NewPostgresStore(db)
+	case config.BackendMemory:
+		memoryRepo := repository.NewSongRepository()
+		if path := cfg.MemoryRestorePath; path != "" {
+			archive, err := readArchive(path)
+			if err == nil {
+				err = backup.Restore(context.Background(), memoryRepo, archive)
+			}
+			if err != nil {
+				fatal("failed to load the backup", "path", path, "error", err)
+			}
+			slog.Info("loaded songs from the backup", "path", path, "groups", len(archive.Groups), "songs", len(archive.Songs))
+		}
+		repo = memoryRepo
+		idempotencyStore = idempotency.NewMemoryStore()
+		memoryTenants := tenant.NewMemoryStore()
+		memoryKeys := auth.NewMemoryStore()
+		for name, value := range cfg.APIKeys {
+			// Значения проверены при загрузке конфигурации
+			key, _ := auth.ParseStaticKey(value)
+			if key.Tenant != "" {
+				// Клиенты ключей создаются без квоты; ошибка — клиент уже есть
+				memoryTenants.Create(context.Background(), tenant.Tenant{Slug: key.Tenant, Name: key.Tenant})
+			}
+			memoryKeys.Create(context.Background(), name, "", key.Hash, key.Role, key.Tenant)
+		}
+		authStore = memoryKeys
+		tenantStore = memoryTenants
+		auditStore = auth.NewMemoryAuditStore()
+	}
+	// JWT от SSO принимаются вместе с API-ключами
+	var jwtVerifier *auth.JWTVerifier
+	if cfg.AuthEnabled && cfg.JWTJWKSFile != "" {
+		jwtVerifier, err = auth.NewJWTVerifier(auth.JWTOptions{
+			JWKSFile:    cfg.JWTJWKSFile,
+			Issuer:      cfg.JWTIssuer,
+			Audience:    cfg.JWTAudience,
+			RoleClaim:   cfg.JWTRoleClaim,
+			RoleMap:     cfg.JWTRoleMap,
+			TenantClaim: cfg.JWTTenantClaim,
+			Leeway:      time.Duration(cfg.JWTLeeway),
+		})
+		if err != nil {
+			fatal("failed to load JWT keys", "error", err)
+		}
+		slog.Info("JWT authentication enabled", "issuer", cfg.JWTIssuer, "audience", cfg.JWTAudience)
+	}
+	if cfg.AuthEnabled {
+		if jwtVerifier == nil {
+			warnIfNoAPIKeys(authStore)
+		}
+	} else {
+		slog.Warn("authentication is disabled, the API is open to anyone who can reach it")
+	}
+
+	// Счетчики ограничения частоты запросов
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitEnabled && cfg.RateLimitStore == config.BackendPostgres {
+		pgLimits := ratelimit.NewPostgresStore(db)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			pgLimits.RunCleanup(workersCtx, 10*time.Minute)
+		}()
+		rateLimitStore = pgLimits
 	}
+	repo = repository.NewInstrumentedRepository(repo)
 
-	// Создаем репозиторий
-	repo := repository.NewSongRepository()
+	// Песни из корзины удаляются окончательно по истечении trash_retention
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		repository.RunPurge(workersCtx, repo, time.Hour, time.Duration(cfg.TrashRetention))
+	}()
 
+	// Метрики Prometheus
+	appMetrics := metrics.New()
+	appMetrics.RegisterSongCount(func(ctx context.Context) (int64, error) {
+		return countAllSongs(ctx, repo, tenantStore)
+	})
+	if db != nil {
+		appMetrics.RegisterDB(db)
+	}
 
 	// Создаем обработчики
-	handler := handlers.NewSongHandler(repo)
+	musicAPI := musicapi.NewClient(cfg.ExternalAPIPath, time.Duration(cfg.ExternalAPITimeout))
+	musicAPI.HTTPClient.Transport = tracing.Transport(appMetrics.InstrumentTransport(http.DefaultTransport))
+	authz := auth.NewAuthorizer(auditStore, cfg.AuthEnabled)
+	handler := handlers.NewSongHandler(repo, musicAPI)
+	handler.Authz = authz
+	handler.TrashRetention = time.Duration(cfg.TrashRetention)
+	auditHandler := handlers.NewAuditHandler(auditStore)
+	tenantHandler := handlers.NewTenantHandler(tenantStore, repo)
+	groupHandler := handlers.NewGroupHandler(repo)
+	importHandler := handlers.NewImportHandler(importer.New(repo, musicAPI))
+	schemaVersion, err := migrations.Latest()
+	if err != nil {
+		fatal("failed to read embedded migrations", "error", err)
+	}
+	healthHandler := handlers.NewHealthHandler(db, musicAPI, buildVersion(), schemaVersion)
 
 
 	// Настраиваем маршруты
 	r := chi.NewRouter()
 
 	// Встраиваем middleware
+	r.Use(logging.RequestIDMiddleware) // ID запроса из X-Request-ID или новый
+	r.Use(RealIPMiddleware(cfg.TrustedProxyPrefixes())) // Адрес клиента из X-Forwarded-For доверенного прокси
+	r.Use(tracing.Middleware)      // Спан запроса, продолжающий входящий traceparent
 	r.Use(LoggerMiddleware)        // Логирование запросов
-	r.Use(middleware.Recoverer)    // Восстановление после паники
-
-	// CRUD операции
-	r.Get("/songs", handler.GetAllSongs)        // Получить список всех песен
-	r.Get("/songs/{id}", handler.GetSongByID)   // Получить песню по ID
-	r.Post("/songs", handler.CreateSong)        // Добавить новую песню
-	r.Put("/songs/{id}", handler.UpdateSong)    // Обновить существующую песню
-	r.Delete("/songs/{id}", handler.DeleteSong) // Удалить песню
-	
-	// Подключение Swagger
-	r.Get("/swagger/*", httpSwagger.WrapHandler)
-	
+	r.Use(appMetrics.Middleware)   // Метрики запросов по шаблону маршрута
+	r.Use(RecoverMiddleware)       // Восстановление после паники
+	r.Use(MaxBodyMiddleware(int64(cfg.MaxBodySize), map[string]int64{"/import": int64(cfg.MaxImportSize)})) // Ограничение размера тела
+
+	// Проверки состояния, метрики и Swagger доступны без ключа
+	r.Get("/healthz", healthHandler.Healthz) // Процесс жив
+	r.Get("/readyz", healthHandler.Readyz)   // Готов принимать запросы
+	r.Get("/metrics", appMetrics.Handler().ServeHTTP) // Метрики в формате Prometheus
+	r.Get("/swagger/*", httpSwagger.WrapHandler)      // Подключение Swagger
+
+	r.Group(func(r chi.Router) {
+		if cfg.RateLimitEnabled {
+			r.Use(ratelimit.IPMiddleware(rateLimitStore, ratelimit.Limit{
+				Rate: cfg.RateLimitIP, Burst: cfg.RateLimitIPBurst,
+			})) // Ограничение частоты запросов с IP-адреса, в том числе с неверными ключами
+		}
+		if cfg.AuthEnabled {
+			r.Use(auth.Middleware(authStore, jwtVerifier)) // Проверка API-ключа или JWT
+		}
+		r.Use(tenant.Middleware(tenantStore)) // Клиент из учетных данных или X-Tenant
+		if cfg.RateLimitEnabled {
+			r.Use(ratelimit.Middleware(rateLimitStore, ratelimit.Limits{
+				Read:  ratelimit.Limit{Rate: cfg.RateLimitRead, Burst: cfg.RateLimitReadBurst},
+				Write: ratelimit.Limit{Rate: cfg.RateLimitWrite, Burst: cfg.RateLimitWriteBurst},
+			})) // Ограничение частоты запросов клиента
+		}
+
+		// Роли: reader читает, editor изменяет песни, admin удаляет и управляет сервисом.
+		// Повторы с Idempotency-Key обрабатываются после проверки роли
+		idempotent := idempotency.Middleware(idempotencyStore, time.Duration(cfg.IdempotencyTTL))
+		reader := r.With(authz.Require(auth.RoleReader), idempotent)
+		editor := r.With(authz.Require(auth.RoleEditor), idempotent)
+		admin := r.With(authz.Require(auth.RoleAdmin), idempotent)
+
+		// CRUD операции
+		reader.Get("/songs", handler.GetAllSongs)              // Получить список всех песен
+		reader.Get("/songs/{id}", handler.GetSongByID)         // Получить песню по ID
+		editor.Post("/songs", handler.CreateSong)              // Добавить новую песню
+		editor.Post("/songs/batch", handler.Batch)             // Пакетные операции с песнями (удаление — только admin)
+		editor.Put("/songs/by-name", handler.UpsertSongByName) // Создать или обновить песню по группе и названию
+		editor.Put("/songs/{id}", handler.UpdateSong)          // Обновить существующую песню
+		editor.Patch("/songs/{id}", handler.PatchSong)         // Частично обновить песню
+		admin.Delete("/songs/{id}", handler.DeleteSong)        // Удалить песню
+
+		// Корзина
+		reader.Get("/trash", handler.ListTrash)                 // Удаленные песни
+		editor.Post("/songs/{id}/restore", handler.RestoreSong) // Вернуть песню из корзины
+
+		// Группы
+		reader.Get("/groups", groupHandler.ListGroups)                // Список групп
+		reader.Get("/groups/{id}", groupHandler.GetGroup)             // Получить группу по ID
+		reader.Get("/groups/{id}/songs", groupHandler.ListGroupSongs) // Песни группы
+		editor.Post("/groups", groupHandler.CreateGroup)              // Добавить группу
+		editor.Put("/groups/{id}", groupHandler.UpdateGroup)          // Обновить или переименовать группу
+		admin.Delete("/groups/{id}", groupHandler.DeleteGroup)        // Удалить группу без песен
+
+		// История изменений
+		reader.Get("/songs/{id}/revisions", handler.ListRevisions)            // История песни
+		reader.Get("/songs/{id}/revisions/diff", handler.DiffRevisions)       // Разница между ревизиями
+		reader.Get("/songs/{id}/revisions/{rev}", handler.GetRevision)        // Одна ревизия
+		editor.Post("/songs/{id}/revisions/{rev}/revert", handler.RevertSong) // Откатить песню к ревизии
+
+		// Импорт каталога
+		r.With(authz.Require(auth.RoleEditor), handlers.ImportDeadline, idempotent).
+			Post("/import", importHandler.Import) // Загрузить песни из CSV или NDJSON
+		editor.Get("/import/{id}/report", importHandler.GetReport) // Скачать отчет об импорте
+		reader.Get("/export", handler.Export)                      // Выгрузить библиотеку в NDJSON, CSV или JSON
+
+		reader.Get("/status", healthHandler.Status)                  // Подробное состояние компонентов
+		admin.Get("/admin/access-denials", auditHandler.ListDenials) // Журнал отказов в доступе
+		admin.Post("/admin/tenants", tenantHandler.CreateTenant)     // Создать клиента
+		admin.Get("/admin/tenants", tenantHandler.ListTenants)       // Список клиентов с числом песен
+	})
+
 	// Запускаем сервер
-	port := os.Getenv("PORT")
-	log.Println("Server is running on port", port)
-	log.Fatal(http.ListenAndServe(port, r))
+	srv := &http.Server{
+		Addr:              cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: min(time.Duration(cfg.ReadTimeout), 10*time.Second),
+		ReadTimeout:       time.Duration(cfg.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.IdleTimeout),
+		ErrorLog:          slog.NewLogLogger(httpLogger.Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server is listening", "addr", cfg.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		slog.Error("server stopped", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining requests")
+	}
+	// Повторный сигнал завершит процесс сразу
+	stop()
+
+	healthHandler.SetShuttingDown()
+	if exitCode == 0 && cfg.ShutdownDelay > 0 {
+		// Даем балансировщику увидеть 503 на /readyz до закрытия соединений
+		time.Sleep(time.Duration(cfg.ShutdownDelay))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("requests did not finish in time", "timeout", cfg.ShutdownTimeout.String(), "error", err)
+		srv.Close()
+		exitCode = 1
+	}
+
+	// Останавливаем фоновые задачи и ждем их в пределах того же срока
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		slog.Error("background jobs did not finish in time")
+		exitCode = 1
+	}
+
+	// Отправляем накопленные спаны
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to flush spans", "error", err)
+	}
+
+	slog.Info("server stopped")
+	return exitCode
+}
+
+// warnIfNoAPIKeys предупреждает, что с включенной аутентификацией без ключей
+// API недоступен.
+func warnIfNoAPIKeys(store auth.Store) {
+	keys, err := store.List(context.Background())
+	if err != nil {
+		slog.Error("failed to list API keys", "error", err)
+		return
+	}
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			return
+		}
+	}
+	slog.Warn("authentication is enabled but there are no API keys, create one with songgo apikey create")
+}
+
+// countAllSongs считает песни всех клиентов: репозиторий видит только
+// библиотеку клиента из контекста.
+func countAllSongs(ctx context.Context, repo repository.Repository, tenants tenant.Store) (int64, error) {
+	list, err := tenants.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, t := range list {
+		n, err := repo.CountSongs(tenant.WithTenant(ctx, &t))
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// buildVersion возвращает версию из -ldflags, а если она не задана — ревизию
+// VCS, записанную компилятором.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return version + "+" + setting.Value[:12]
+		}
+	}
+	return version
+}
+
+// MaxBodyMiddleware ограничивает размер тела запроса. Для путей из overrides
+// действует собственный лимит. Запросы с заведомо большим Content-Length
+// отклоняются сразу с 413.
+func MaxBodyMiddleware(limit int64, overrides map[string]int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			size := limit
+			if v, ok := overrides[r.URL.Path]; ok {
+				size = v
+			}
+			if r.ContentLength > size {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, size)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RealIPMiddleware заменяет r.RemoteAddr адресом клиента из X-Forwarded-For,
+// если запрос пришел от доверенного прокси; от этого адреса считаются лимиты
+// и пишутся логи. Заголовок разбирается справа налево: адреса доверенных
+// прокси пропускаются, а первый чужой адрес считается клиентом — все, что
+// левее, мог подставить сам клиент. Без доверенных прокси заголовок игнорируется.
+func RealIPMiddleware(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip, ok := forwardedClientIP(r, trusted); ok {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP возвращает адрес клиента из X-Forwarded-For или false,
+// если запрос пришел не от доверенного прокси или заголовок некорректен.
+func forwardedClientIP(r *http.Request, trusted []netip.Prefix) (string, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !isTrustedProxy(peer.Addr(), trusted) {
+		return "", false
+	}
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return "", false
+		}
+		client = addr.Unmap().String()
+		if !isTrustedProxy(addr, trusted) {
+			break
+		}
+	}
+	return client, client != ""
 }
 
-// LoggerMiddleware логирует информацию о каждом запросе.
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+var httpLogger = logging.For(logging.ComponentHTTP)
+
+// LoggerMiddleware логирует информацию о каждом запросе. ID запроса берется
+// из контекста, поэтому middleware подключается после RequestIDMiddleware.
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-
-		log.Printf("[INFO] Incoming request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		httpLogger.DebugContext(r.Context(), "request started",
+			"method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
 
 		// Передаём управление следующему обработчику
-		next.ServeHTTP(w, r)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
 
-		duration := time.Since(start)
-		log.Printf("[INFO] Completed request: %s %s in %v", r.Method, r.URL.Path, duration)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		httpLogger.LogAttrs(r.Context(), level, "request completed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
+// RecoverMiddleware перехватывает панику обработчика, пишет ее в лог со стеком
+// и отвечает 500.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			// Штатный способ оборвать ответ — пропускаем дальше
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+			httpLogger.ErrorContext(r.Context(), "panic in handler",
+				"panic", fmt.Sprint(rvr), "stack", string(debug.Stack()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
 	})
 }
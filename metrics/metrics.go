@@ -0,0 +1,189 @@
+// Package metrics собирает метрики сервиса в формате Prometheus.
+//
+// Метки намеренно ограничены: вместо пути запроса используется шаблон маршрута
+// chi ("/songs/{id}"), чтобы ID песен не порождали новые временные ряды.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/logging"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var logger = logging.For(logging.ComponentMetrics)
+
+const namespace = "songgo"
+
+// Маршрут для запросов, не совпавших ни с одним шаблоном.
+const unmatchedRoute = "unmatched"
+
+// Метка для нестандартных HTTP-методов, чтобы клиент не мог раздуть число рядов.
+const otherMethod = "OTHER"
+
+// Стандартные методы, которые попадают в метку method как есть.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Metrics хранит реестр и метрики сервиса.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	externalDuration *prometheus.HistogramVec
+	externalErrors   *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		externalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "external_api_request_duration_seconds",
+			Help:      "External song info API latency by outcome (ok, client_error, server_error, network_error).",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		externalErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "external_api_errors_total",
+			Help:      "Failed external song info API calls by reason (server_error, network_error).",
+		}, []string{"reason"}),
+	}
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		m.externalDuration,
+		m.externalErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler отдает метрики в текстовом формате Prometheus.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware считает запросы и их длительность по шаблону маршрута chi.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		// Шаблон известен только после маршрутизации
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = otherMethod
+		}
+		labels := prometheus.Labels{"method": method, "route": route, "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// InstrumentTransport оборачивает транспорт HTTP-клиента внешнего API.
+func (m *Metrics) InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+
+		outcome := "ok"
+		switch {
+		case err != nil:
+			outcome = "network_error"
+		case resp.StatusCode >= 500:
+			outcome = "server_error"
+		case resp.StatusCode >= 400:
+			outcome = "client_error"
+		}
+		m.externalDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+		if outcome == "network_error" || outcome == "server_error" {
+			m.externalErrors.WithLabelValues(outcome).Inc()
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// RegisterDB добавляет статистику пула соединений sql.DB.
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, "songgo"))
+}
+
+// RegisterSongCount добавляет число песен, которое считается при каждом сборе метрик.
+func (m *Metrics) RegisterSongCount(count func(ctx context.Context) (int64, error)) {
+	m.registry.MustRegister(&songCountCollector{
+		count: count,
+		desc:  prometheus.NewDesc(namespace+"_songs", "Number of songs in the library.", nil, nil),
+	})
+}
+
+// Сколько ждать подсчета песен при сборе метрик.
+const songCountTimeout = 2 * time.Second
+
+type songCountCollector struct {
+	count func(ctx context.Context) (int64, error)
+	desc  *prometheus.Desc
+}
+
+func (c *songCountCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *songCountCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), songCountTimeout)
+	defer cancel()
+
+	n, err := c.count(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to count songs", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
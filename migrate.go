@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/config"
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/golang-migrate/migrate/v4"
+)
+
+var migrateLog = logging.For(logging.ComponentMigrate)
+
+const migrateUsage = `Usage: songgo migrate [flags] <command>
+
+Commands:
+  up         apply all pending migrations
+  down N     roll back the last N migrations
+  goto V     migrate up or down to version V
+  version    print the current version
+  force V    set the version without running migrations (after a failed migration)
+`
+
+// runMigrate управляет миграциями базы данных. В отличие от остальных команд
+// не применяет миграции автоматически.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage+"\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	cfg := loadConfig(fs, args)
+	args = fs.Args()
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+
+	// Для up и version аргумент не нужен, для остальных — ровно одно число
+	var n int
+	switch command {
+	case "up", "version":
+		if len(args) != 0 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+	case "down", "goto", "force":
+		var err error
+		if len(args) == 1 {
+			n, err = strconv.Atoi(args[0])
+		}
+		if len(args) != 1 || err != nil || n < 0 || (command == "down" && n == 0) {
+			fmt.Fprintf(os.Stderr, "migrate %s: expected a non-negative number\n\n%s", command, migrateUsage)
+			return 2
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", command, migrateUsage)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	db, err := config.ConnectDB(ctx, cfg.DatabasePath)
+	if err != nil {
+		migrateLog.Error("failed to connect to the database", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := config.NewMigrator(db)
+	if err != nil {
+		migrateLog.Error("failed to create the migrator", "error", err)
+		return 1
+	}
+	defer migrator.Close()
+	migrator.Log = migrateLogger{}
+
+	switch command {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Steps(-n)
+	case "goto":
+		err = migrator.Migrate(uint(n))
+	case "force":
+		err = migrator.Force(n)
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		migrateLog.Info("no changes")
+		err = nil
+	}
+	if err != nil {
+		migrateLog.Error("migration failed", "command", command, "error", err)
+		return 1
+	}
+
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("version: none")
+		return 0
+	}
+	if err != nil {
+		migrateLog.Error("failed to read the schema version", "error", err)
+		return 1
+	}
+	if dirty {
+		fmt.Printf("version: %d (dirty)\n", version)
+		return 1
+	}
+	fmt.Printf("version: %d\n", version)
+	return 0
+}
+
+// migrateLogger выводит ход миграций golang-migrate в лог компонента migrate.
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	migrateLog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool { return false }
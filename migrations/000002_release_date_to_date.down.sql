@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS songs_release_date_idx;
+
+ALTER TABLE songs
+    ALTER COLUMN release_date TYPE TEXT USING CASE release_date_precision
+        WHEN 'year' THEN to_char(release_date, 'YYYY')
+        WHEN 'month' THEN to_char(release_date, 'MM.YYYY')
+        ELSE to_char(release_date, 'DD.MM.YYYY')
+    END;
+
+ALTER TABLE songs DROP COLUMN release_date_precision;
+
+DROP TYPE date_precision;
@@ -0,0 +1,51 @@
+-- Точность даты выпуска: внешний API иногда отдает только год или месяц и год.
+CREATE TYPE date_precision AS ENUM ('day', 'month', 'year');
+
+-- Разбор текстовых дат вида "16.07.2006", "2006-07-16", "07.2006", "2006-07" и "2006".
+-- Для нераспознанных или некорректных значений возвращает NULL.
+CREATE FUNCTION pg_temp.parse_release_date(raw TEXT, OUT d DATE, OUT p date_precision) AS $$
+BEGIN
+    raw := btrim(raw);
+    IF raw ~ '^\d{1,2}\.\d{1,2}\.\d{4}$' THEN
+        d := to_date(raw, 'DD.MM.YYYY'); p := 'day';
+    ELSIF raw ~ '^\d{4}-\d{1,2}-\d{1,2}$' THEN
+        d := to_date(raw, 'YYYY-MM-DD'); p := 'day';
+    ELSIF raw ~ '^\d{1,2}\.\d{4}$' THEN
+        d := to_date(raw, 'MM.YYYY'); p := 'month';
+    ELSIF raw ~ '^\d{4}-\d{1,2}$' THEN
+        d := to_date(raw, 'YYYY-MM'); p := 'month';
+    ELSIF raw ~ '^\d{4}$' THEN
+        d := to_date(raw, 'YYYY'); p := 'year';
+    END IF;
+EXCEPTION WHEN others THEN
+    d := NULL; p := NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Нераспознанные даты не теряем молча: миграция завершается ошибкой со списком
+-- таких песен, даты нужно исправить или очистить вручную, после чего выполнить
+-- songgo migrate force 1 и повторить миграцию. Пустая дата становится NULL.
+DO $$
+DECLARE
+    invalid TEXT;
+BEGIN
+    SELECT string_agg(format('id %s: %s / %s: %L', id, group_name, song, release_date), E'\n' ORDER BY id)
+    INTO invalid
+    FROM songs
+    WHERE btrim(coalesce(release_date, '')) <> ''
+      AND (pg_temp.parse_release_date(release_date)).d IS NULL;
+
+    IF invalid IS NOT NULL THEN
+        RAISE EXCEPTION 'songs with release dates that are not DD.MM.YYYY, YYYY-MM-DD, MM.YYYY, YYYY-MM or YYYY must be fixed first'
+            USING DETAIL = invalid;
+    END IF;
+END $$;
+
+ALTER TABLE songs ADD COLUMN release_date_precision date_precision NOT NULL DEFAULT 'day';
+
+UPDATE songs SET release_date_precision = COALESCE((pg_temp.parse_release_date(release_date)).p, 'day');
+
+ALTER TABLE songs
+    ALTER COLUMN release_date TYPE DATE USING (pg_temp.parse_release_date(release_date)).d;
+
+CREATE INDEX songs_release_date_idx ON songs (release_date);
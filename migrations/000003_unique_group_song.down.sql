@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS songs_group_song_key;
+
+DROP FUNCTION IF EXISTS normalize_name(TEXT);
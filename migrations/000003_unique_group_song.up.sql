@@ -0,0 +1,30 @@
+-- Нормализованное имя: без учета регистра, крайних пробелов и повторяющихся пробелов внутри.
+CREATE FUNCTION normalize_name(name TEXT) RETURNS TEXT AS $$
+    SELECT lower(btrim(regexp_replace(name, '\s+', ' ', 'g')));
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- Дубликаты не удаляем молча: миграция завершается ошибкой со списком
+-- конфликтующих пар, их нужно объединить или переименовать вручную,
+-- после чего выполнить songgo migrate force 2 и повторить миграцию.
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(format('%s / %s (ids %s)', group_name, song, ids), E'\n')
+    INTO conflicts
+    FROM (
+        SELECT min(group_name) AS group_name, min(song) AS song,
+               string_agg(id::TEXT, ', ' ORDER BY id) AS ids
+        FROM songs
+        GROUP BY normalize_name(group_name), normalize_name(song)
+        HAVING count(*) > 1
+        ORDER BY 1, 2
+    ) d;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'songs with the same group and title differing only in case or spaces must be merged first'
+            USING DETAIL = conflicts;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX songs_group_song_key ON songs (normalize_name(group_name), normalize_name(song));
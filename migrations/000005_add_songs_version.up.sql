@@ -0,0 +1,2 @@
+-- Версия песни для ETag и оптимистичной блокировки: увеличивается при каждом изменении.
+ALTER TABLE songs ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
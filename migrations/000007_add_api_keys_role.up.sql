@@ -0,0 +1,5 @@
+-- Роль API-ключа: reader, editor или admin. Ключи, созданные до появления
+-- ролей, имели полный доступ и получают admin; новые по умолчанию — reader.
+ALTER TABLE api_keys ADD COLUMN role TEXT NOT NULL DEFAULT 'admin'
+    CHECK (role IN ('reader', 'editor', 'admin'));
+ALTER TABLE api_keys ALTER COLUMN role SET DEFAULT 'reader';
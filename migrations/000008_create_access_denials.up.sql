@@ -0,0 +1,15 @@
+-- Журнал запросов, отклоненных из-за недостаточной роли (403).
+CREATE TABLE IF NOT EXISTS access_denials (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    subject TEXT NOT NULL,
+    key_id INTEGER,
+    role TEXT NOT NULL,
+    required_role TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    remote_addr TEXT NOT NULL,
+    request_id TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS access_denials_created_at_idx ON access_denials (created_at);
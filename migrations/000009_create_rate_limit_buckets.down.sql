@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS rate_limit_refill;
+DROP TABLE IF EXISTS rate_limit_buckets;
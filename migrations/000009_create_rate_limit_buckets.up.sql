@@ -0,0 +1,17 @@
+-- Корзины токенов для ограничения частоты запросов, общие для всех экземпляров.
+-- К expires_at корзина гарантированно заполняется снова, и запись можно удалить.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_expires_at_idx ON rate_limit_buckets (expires_at);
+
+-- Токены в корзине с учетом пополнения за время с updated_at.
+CREATE FUNCTION rate_limit_refill(tokens DOUBLE PRECISION, updated_at TIMESTAMPTZ, burst DOUBLE PRECISION, rate DOUBLE PRECISION)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT LEAST(burst, tokens + EXTRACT(EPOCH FROM now() - updated_at)::DOUBLE PRECISION * rate);
+$$ LANGUAGE sql STABLE STRICT;
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS songs_tenant_quota ON songs;
+DROP FUNCTION IF EXISTS check_tenant_quota;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+
+-- Без клиентов пара группа + название снова уникальна глобально:
+-- из одинаковых песен разных клиентов остается самая ранняя.
+DROP INDEX IF EXISTS songs_group_song_key;
+DELETE FROM songs s
+USING songs d
+WHERE normalize_name(s.group_name) = normalize_name(d.group_name)
+  AND normalize_name(s.song) = normalize_name(d.song)
+  AND s.id > d.id;
+CREATE UNIQUE INDEX songs_group_song_key ON songs (normalize_name(group_name), normalize_name(song));
+
+ALTER TABLE songs DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
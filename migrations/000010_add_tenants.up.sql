@@ -0,0 +1,42 @@
+-- Клиенты (арендаторы), библиотеки которых хранятся раздельно. Существующие
+-- песни и ключи переходят к клиенту default.
+CREATE TABLE IF NOT EXISTS tenants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9][a-z0-9-]{0,62}$'),
+    name TEXT NOT NULL,
+    max_songs INTEGER CHECK (max_songs >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO tenants (id, slug, name) VALUES (1, 'default', 'Default') ON CONFLICT DO NOTHING;
+SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT MAX(id) FROM tenants));
+
+ALTER TABLE songs ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1 REFERENCES tenants (id);
+ALTER TABLE songs ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Группа и название уникальны в пределах клиента
+DROP INDEX IF EXISTS songs_group_song_key;
+CREATE UNIQUE INDEX songs_group_song_key ON songs (tenant_id, normalize_name(group_name), normalize_name(song));
+
+-- Существующие ключи привязываются к default; ключ без клиента, который
+-- выбирает его заголовком X-Tenant, выдается только явно
+ALTER TABLE api_keys ADD COLUMN tenant_id INTEGER DEFAULT 1 REFERENCES tenants (id);
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- Квота клиента проверяется после вставки: блокировка строки клиента
+-- упорядочивает параллельные вставки, поэтому квоту нельзя превысить гонкой.
+CREATE FUNCTION check_tenant_quota() RETURNS trigger AS $$
+DECLARE
+    quota INTEGER;
+BEGIN
+    SELECT max_songs INTO quota FROM tenants WHERE id = NEW.tenant_id FOR UPDATE;
+    IF quota IS NOT NULL AND (SELECT count(*) FROM songs WHERE tenant_id = NEW.tenant_id) > quota THEN
+        RAISE EXCEPTION 'tenant % song quota of % exceeded', NEW.tenant_id, quota
+            USING ERRCODE = 'SG001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER songs_tenant_quota AFTER INSERT ON songs
+    FOR EACH ROW EXECUTE FUNCTION check_tenant_quota();
@@ -0,0 +1,27 @@
+-- История изменений песен: каждая запись — полный снимок песни после
+-- создания, изменения, отката или перед удалением. song_id без внешнего
+-- ключа, чтобы история удаленных песен сохранялась.
+CREATE TABLE IF NOT EXISTS song_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+    song_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'revert')),
+    actor TEXT,
+    reverted_from INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    group_name TEXT NOT NULL,
+    song TEXT NOT NULL,
+    release_date DATE,
+    release_date_precision date_precision NOT NULL,
+    song_text TEXT,
+    link TEXT,
+    version INTEGER NOT NULL,
+    UNIQUE (song_id, revision)
+);
+
+-- Существующие песни получают первую ревизию с текущим состоянием
+INSERT INTO song_revisions (tenant_id, song_id, revision, action, group_name, song, release_date,
+    release_date_precision, song_text, link, version)
+SELECT tenant_id, id, 1, 'create', group_name, song, release_date, release_date_precision, song_text, link, version
+FROM songs;
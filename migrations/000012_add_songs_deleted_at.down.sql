@@ -0,0 +1,31 @@
+-- Песни из корзины удаляются окончательно
+DELETE FROM songs WHERE deleted_at IS NOT NULL;
+
+-- Восстановление из корзины становится обычным изменением
+UPDATE song_revisions SET action = 'update' WHERE action = 'restore';
+ALTER TABLE song_revisions DROP CONSTRAINT IF EXISTS song_revisions_action_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'revert'));
+
+DROP TRIGGER IF EXISTS songs_tenant_quota ON songs;
+CREATE TRIGGER songs_tenant_quota AFTER INSERT ON songs
+    FOR EACH ROW EXECUTE FUNCTION check_tenant_quota();
+
+CREATE OR REPLACE FUNCTION check_tenant_quota() RETURNS trigger AS $$
+DECLARE
+    quota INTEGER;
+BEGIN
+    SELECT max_songs INTO quota FROM tenants WHERE id = NEW.tenant_id FOR UPDATE;
+    IF quota IS NOT NULL AND (SELECT count(*) FROM songs WHERE tenant_id = NEW.tenant_id) > quota THEN
+        RAISE EXCEPTION 'tenant % song quota of % exceeded', NEW.tenant_id, quota
+            USING ERRCODE = 'SG001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS songs_group_song_key;
+CREATE UNIQUE INDEX songs_group_song_key ON songs (tenant_id, normalize_name(group_name), normalize_name(song));
+
+DROP INDEX IF EXISTS songs_deleted_at_idx;
+ALTER TABLE songs DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,31 @@
+-- Мягкое удаление: удаленная песня остается в корзине до очистки.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS songs_deleted_at_idx ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Группа и название уникальны только среди неудаленных песен
+DROP INDEX IF EXISTS songs_group_song_key;
+CREATE UNIQUE INDEX songs_group_song_key ON songs (tenant_id, normalize_name(group_name), normalize_name(song))
+    WHERE deleted_at IS NULL;
+
+-- Песни в корзине не занимают квоту, поэтому она проверяется и при восстановлении
+CREATE OR REPLACE FUNCTION check_tenant_quota() RETURNS trigger AS $$
+DECLARE
+    quota INTEGER;
+BEGIN
+    SELECT max_songs INTO quota FROM tenants WHERE id = NEW.tenant_id FOR UPDATE;
+    IF quota IS NOT NULL AND (SELECT count(*) FROM songs WHERE tenant_id = NEW.tenant_id AND deleted_at IS NULL) > quota THEN
+        RAISE EXCEPTION 'tenant % song quota of % exceeded', NEW.tenant_id, quota
+            USING ERRCODE = 'SG001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS songs_tenant_quota ON songs;
+CREATE TRIGGER songs_tenant_quota AFTER INSERT OR UPDATE OF deleted_at ON songs
+    FOR EACH ROW WHEN (NEW.deleted_at IS NULL) EXECUTE FUNCTION check_tenant_quota();
+
+ALTER TABLE song_revisions DROP CONSTRAINT IF EXISTS song_revisions_action_check;
+ALTER TABLE song_revisions ADD CONSTRAINT song_revisions_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'revert'));
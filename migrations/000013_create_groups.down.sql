@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS songs_resolve_group ON songs;
+DROP FUNCTION IF EXISTS resolve_song_group();
+
+ALTER TABLE song_revisions DROP COLUMN IF EXISTS group_id;
+
+-- Имена групп уже записаны в group_name песен
+DROP INDEX IF EXISTS songs_group_id_idx;
+ALTER TABLE songs DROP COLUMN IF EXISTS group_id;
+
+DROP TABLE IF EXISTS groups;
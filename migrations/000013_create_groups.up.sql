@@ -0,0 +1,65 @@
+-- Группы (исполнители) клиента. Песня ссылается на группу по group_id, а
+-- group_name остается копией имени группы для чтения и уникальности песен.
+CREATE TABLE IF NOT EXISTS groups (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+    name TEXT NOT NULL,
+    country TEXT CHECK (country ~ '^[A-Z]{2}$'),
+    formed_year INTEGER CHECK (formed_year BETWEEN 1000 AND 9999),
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS groups_name_key ON groups (tenant_id, normalize_name(name));
+
+-- Названия групп, отличающиеся только регистром и пробелами, — одна группа;
+-- ее имя берется из самой ранней песни
+INSERT INTO groups (tenant_id, name)
+SELECT DISTINCT ON (tenant_id, normalize_name(group_name)) tenant_id, group_name
+FROM songs
+ORDER BY tenant_id, normalize_name(group_name), id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES groups (id);
+UPDATE songs SET group_id = groups.id, group_name = groups.name
+FROM groups
+WHERE groups.tenant_id = songs.tenant_id AND normalize_name(groups.name) = normalize_name(songs.group_name);
+ALTER TABLE songs ALTER COLUMN group_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS songs_group_id_idx ON songs (group_id);
+
+-- Снимки в истории запоминают группу; у песен, удаленных до появления групп,
+-- ее может не быть
+ALTER TABLE song_revisions ADD COLUMN IF NOT EXISTS group_id INTEGER;
+UPDATE song_revisions SET group_id = groups.id
+FROM groups
+WHERE groups.tenant_id = song_revisions.tenant_id
+  AND normalize_name(groups.name) = normalize_name(song_revisions.group_name);
+
+-- Группа песни находится по group_name без учета регистра и пробелов или
+-- создается; group_name приводится к имени группы. Повторный поиск после
+-- вставки нужен, если ту же группу одновременно создала другая транзакция.
+CREATE OR REPLACE FUNCTION resolve_song_group() RETURNS trigger AS $$
+DECLARE
+    found groups%ROWTYPE;
+BEGIN
+    SELECT * INTO found FROM groups
+    WHERE tenant_id = NEW.tenant_id AND normalize_name(name) = normalize_name(NEW.group_name);
+    IF NOT FOUND THEN
+        INSERT INTO groups (tenant_id, name) VALUES (NEW.tenant_id, btrim(NEW.group_name))
+        ON CONFLICT DO NOTHING
+        RETURNING * INTO found;
+        IF NOT FOUND THEN
+            SELECT * INTO found FROM groups
+            WHERE tenant_id = NEW.tenant_id AND normalize_name(name) = normalize_name(NEW.group_name);
+        END IF;
+    END IF;
+    NEW.group_id := found.id;
+    NEW.group_name := found.name;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS songs_resolve_group ON songs;
+CREATE TRIGGER songs_resolve_group BEFORE INSERT OR UPDATE OF group_name ON songs
+    FOR EACH ROW EXECUTE FUNCTION resolve_song_group();
@@ -0,0 +1,35 @@
+// Package migrations встраивает SQL-миграции в бинарный файл, чтобы сервер
+// не зависел от рабочего каталога.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// FS содержит файлы миграций golang-migrate (*.up.sql и *.down.sql).
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Latest возвращает номер последней встроенной миграции — версию схемы,
+// которую ожидает этот бинарный файл.
+func Latest() (uint, error) {
+	entries, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint
+	for _, name := range entries {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration file name %q", name)
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
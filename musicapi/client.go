@@ -0,0 +1,108 @@
+package musicapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/Kitrop/songGO-lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var logger = logging.For(logging.ComponentMusicAPI)
+
+// SongInfo — дополнительные сведения о песне из внешнего API.
+type SongInfo struct {
+	ReleaseDate string `json:"releaseDate"`
+	Text        string `json:"text"`
+	Link        string `json:"link"`
+}
+
+// Client обращается к внешнему API с информацией о песнях.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	failures failureTracker
+}
+
+// NewClient создает клиент, который ждет ответа внешнего API не дольше timeout.
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{BaseURL: baseURL, HTTPClient: &http.Client{Timeout: timeout}}
+}
+
+// Health возвращает состояние внешнего API по последним запросам.
+func (c *Client) Health() Health {
+	return c.failures.snapshot()
+}
+
+// SongInfo запрашивает сведения о песне по группе и названию.
+func (c *Client) SongInfo(ctx context.Context, group, song string) (*SongInfo, error) {
+	ctx, span := tracing.Start(ctx, "musicapi.SongInfo",
+		attribute.String("song.group", group), attribute.String("song.title", song))
+	start := time.Now()
+	info, err := c.songInfo(ctx, group, song)
+	tracing.End(span, err)
+	if err != nil {
+		logger.WarnContext(ctx, "external API call failed",
+			slog.Duration("duration", time.Since(start)), slog.String("error", err.Error()))
+	} else {
+		logger.DebugContext(ctx, "external API call", slog.Duration("duration", time.Since(start)))
+	}
+	// Отмена запроса клиентом и ответы 4xx не говорят о недоступности API
+	var statusErr *StatusError
+	switch {
+	case ctx.Err() != nil:
+	case errors.As(err, &statusErr) && statusErr.StatusCode < 500:
+		c.failures.record(nil)
+	default:
+		c.failures.record(err)
+	}
+	return info, err
+}
+
+// StatusError — ответ внешнего API с кодом, отличным от 200.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "external API returned " + e.Status
+}
+
+func (c *Client) songInfo(ctx context.Context, group, song string) (*SongInfo, error) {
+	query := url.Values{}
+	query.Set("group", group)
+	query.Set("song", song)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Передаем ID запроса, чтобы его можно было найти в логах внешнего API
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var info SongInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("invalid response from external API: %w", err)
+	}
+	return &info, nil
+}
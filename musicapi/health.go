@@ -0,0 +1,58 @@
+package musicapi
+
+import (
+	"sync"
+	"time"
+)
+
+// После стольких ошибок подряд внешний API считается сбоящим.
+const failureThreshold = 5
+
+// Состояния внешнего API по последним запросам.
+const (
+	StateHealthy = "healthy" // последние запросы проходят
+	StateFailing = "failing" // последние failureThreshold запросов завершились ошибкой
+)
+
+// Health — сведения о доступности внешнего API по последним запросам.
+type Health struct {
+	State               string     `json:"state" enums:"healthy,failing" example:"healthy"`
+	ConsecutiveFailures int        `json:"consecutiveFailures" example:"0"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LastFailure         *time.Time `json:"lastFailure,omitempty"`
+}
+
+// failureTracker считает ошибки запросов к внешнему API подряд. Запросы он не
+// блокирует: состояние только показывается в /status.
+type failureTracker struct {
+	mu     sync.Mutex
+	health Health
+}
+
+func (t *failureTracker) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if err == nil {
+		t.health.ConsecutiveFailures = 0
+		t.health.LastSuccess = &now
+		return
+	}
+	t.health.ConsecutiveFailures++
+	t.health.LastError = err.Error()
+	t.health.LastFailure = &now
+}
+
+func (t *failureTracker) snapshot() Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.health
+	h.State = StateHealthy
+	if h.ConsecutiveFailures >= failureThreshold {
+		h.State = StateFailing
+	}
+	return h
+}
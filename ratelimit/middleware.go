@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+)
+
+// Классы запросов: у чтения и изменения отдельные корзины, а корзина IP-адреса
+// общая для всех запросов.
+const (
+	ClassRead  = "read"
+	ClassWrite = "write"
+	ClassIP    = "ip"
+)
+
+// Limits — лимиты для каждого класса запросов.
+type Limits struct {
+	Read  Limit
+	Write Limit
+}
+
+// Middleware ограничивает частоту запросов каждого клиента. Клиент
+// определяется по API-ключу или JWT, если запрос прошел аутентификацию,
+// иначе по IP-адресу; поэтому подключается после auth.Middleware.
+// Ответ содержит заголовки RateLimit-*; при превышении лимита сервер
+// отвечает 429 с Retry-After. Если хранилище недоступно, запрос пропускается.
+func Middleware(store Store, limits Limits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			class, limit := ClassWrite, limits.Write
+			if isRead(r.Method) {
+				class, limit = ClassRead, limits.Read
+			}
+			if allow(w, r, store, class, clientKey(r), limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// IPMiddleware ограничивает частоту всех запросов с одного IP-адреса и
+// подключается до auth.Middleware: иначе запросы с неверными ключами или
+// токенами, отклоненные с 401, не ограничивались бы вовсе. Лимит должен быть
+// выше лимитов клиента в Middleware, так как за одним адресом может быть
+// несколько клиентов. Адрес берется из r.RemoteAddr; за балансировщиком его
+// подставляет из X-Forwarded-For RealIPMiddleware сервера.
+func IPMiddleware(store Store, limit Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allow(w, r, store, ClassIP, ipKey(r), limit) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// allow берет токен из корзины class:client и выставляет заголовки RateLimit-*.
+// Если лимит превышен, отвечает 429 и возвращает false.
+func allow(w http.ResponseWriter, r *http.Request, store Store, class, client string, limit Limit) bool {
+	res, err := store.Take(r.Context(), class+":"+client, limit)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to check rate limit", "client", client, "error", err)
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(seconds(float64(limit.Burst)/limit.Rate))))
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		retryAfter := max(ceilSeconds(res.RetryAfter), 1)
+		logger.DebugContext(r.Context(), "rate limit exceeded", "client", client, "class", class,
+			"retry_after", retryAfter)
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+		http.Error(w, fmt.Sprintf("rate limit exceeded: retry in %d seconds", retryAfter), http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// clientKey возвращает идентификатор клиента для корзины.
+func clientKey(r *http.Request) string {
+	if id := auth.FromContext(r.Context()); id != nil {
+		return id.Key()
+	}
+	return ipKey(r)
+}
+
+// ipKey возвращает идентификатор IP-адреса запроса.
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,154 @@
+// Package ratelimit ограничивает частоту запросов клиентов алгоритмом
+// корзины токенов (token bucket).
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+)
+
+var logger = logging.For(logging.ComponentRateLimit)
+
+// Limit — параметры корзины: Rate токенов в секунду, не больше Burst
+// токенов сразу. Каждый запрос забирает один токен.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result — результат попытки забрать токен.
+type Result struct {
+	Allowed bool
+	// Remaining — сколько запросов можно сделать сразу.
+	Remaining int
+	// RetryAfter — через сколько появится следующий токен; 0, если запрос разрешен.
+	RetryAfter time.Duration
+	// Reset — через сколько корзина заполнится полностью.
+	Reset time.Duration
+}
+
+// newResult вычисляет результат по числу токенов, оставшихся в корзине.
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// Store хранит корзины клиентов.
+type Store interface {
+	// Take забирает токен из корзины key, предварительно пополнив ее за
+	// время с прошлого запроса. Новая корзина создается полной.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Как часто MemoryStore удаляет корзины, которые уже заполнились.
+const memorySweepInterval = time.Minute
+
+// MemoryStore хранит корзины в памяти процесса. У каждого экземпляра
+// сервиса свои корзины.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// К этому моменту корзина заполнится, и ее можно удалить
+	fullAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) >= memorySweepInterval {
+		for k, b := range s.buckets {
+			if now.After(b.fullAt) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	b, exists := s.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updatedAt).Seconds()*limit.Rate)
+	b.updatedAt = now
+	b.fullAt = now.Add(seconds(float64(limit.Burst) / limit.Rate))
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newResult(limit, b.tokens, allowed), nil
+}
+
+// PostgresStore хранит корзины в таблице rate_limit_buckets, чтобы лимит
+// был общим для всех экземпляров сервиса.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{queries: database.New(db)}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	row, err := s.queries.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.Rate,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(limit, row.Tokens, row.Allowed), nil
+}
+
+// RunCleanup периодически удаляет заполнившиеся корзины, пока не отменен ctx.
+func (s *PostgresStore) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.queries.DeleteExpiredRateLimitBuckets(ctx)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to delete expired rate limit buckets", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.DebugContext(ctx, "deleted expired rate limit buckets", "count", deleted)
+			}
+		}
+	}
+}
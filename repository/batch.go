@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+// BatchOp — тип операции в пакетном запросе.
+type BatchOp string
+
+const (
+	BatchCreate BatchOp = "create"
+	BatchUpdate BatchOp = "update"
+	BatchDelete BatchOp = "delete"
+)
+
+// ErrInvalidOperation возвращается для некорректно заданной операции пакета.
+var ErrInvalidOperation = errors.New("invalid batch operation")
+
+// ErrBatchAborted — результат операций атомарного пакета, который был
+// отменен из-за ошибки в другой операции.
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchOperation — одна операция пакета. Для create и update используется Song,
+// для update и delete — ID. ExpectedVersion работает как в UpdateSong.
+type BatchOperation struct {
+	Op              BatchOp
+	ID              int32
+	ExpectedVersion int32
+	Song            *database.Song
+}
+
+// BatchResult — результат одной операции пакета. Song заполняется для create и update.
+type BatchResult struct {
+	Song *database.Song
+	Err  error
+}
+
+// applyOperation выполняет одну операцию пакета в переданном хранилище.
+func applyOperation(ctx context.Context, repo Repository, op BatchOperation) BatchResult {
+	switch op.Op {
+	case BatchCreate:
+		if !validSong(op.Song) {
+			return BatchResult{Err: fmt.Errorf("%w: create requires song with groupName or groupId and song", ErrInvalidOperation)}
+		}
+		created, err := repo.CreateSong(ctx, op.Song)
+		return BatchResult{Song: created, Err: err}
+	case BatchUpdate:
+		if op.ID == 0 || !validSong(op.Song) {
+			return BatchResult{Err: fmt.Errorf("%w: update requires id and song with groupName or groupId and song", ErrInvalidOperation)}
+		}
+		op.Song.ID = op.ID
+		if err := repo.UpdateSong(ctx, op.Song, op.ExpectedVersion); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Song: op.Song}
+	case BatchDelete:
+		return BatchResult{Err: repo.DeleteSong(ctx, op.ID, op.ExpectedVersion)}
+	default:
+		return BatchResult{Err: fmt.Errorf("%w: unknown operation %q", ErrInvalidOperation, op.Op)}
+	}
+}
+
+// validSong проверяет, что у песни есть название и группа — по имени или ID.
+func validSong(song *database.Song) bool {
+	return song != nil && song.Song != "" && (song.GroupName != "" || song.GroupID != 0)
+}
+
+// abortBatch помечает все результаты атомарного пакета, кроме ошибочного, как отмененные.
+func abortBatch(results []BatchResult, failed int) {
+	for i := range results {
+		if i != failed {
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+}
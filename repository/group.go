@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+// ErrGroupNotFound возвращается, если у клиента нет группы с указанным ID.
+var ErrGroupNotFound = errors.New("group not found")
+
+// ErrGroupExists возвращается, если у клиента уже есть группа с таким же
+// именем (без учета регистра и пробелов).
+var ErrGroupExists = errors.New("group already exists")
+
+// ErrGroupMismatch возвращается, если у песни заданы и GroupID, и название
+// группы, но название не совпадает с именем группы с этим ID.
+var ErrGroupMismatch = errors.New("groupName does not match the group with groupId")
+
+// ErrGroupNotEmpty возвращается при удалении группы, у которой есть песни,
+// в том числе в корзине.
+var ErrGroupNotEmpty = errors.New("group has songs")
+
+// Group — группа (исполнитель). Песни ссылаются на группу по ID; группа
+// создается автоматически, когда появляется песня с новым названием группы.
+type Group struct {
+	ID   int32  `json:"id" example:"1"`
+	Name string `json:"name" example:"Muse"`
+	// Country — код страны ISO 3166-1 alpha-2
+	Country    string `json:"country,omitempty" example:"GB"`
+	FormedYear int32  `json:"formedYear,omitempty" example:"1994"`
+	// Aliases — другие названия группы, по ним тоже ищется группа
+	Aliases   []string  `json:"aliases" example:"Rocket Baby Dolls"`
+	CreatedAt time.Time `json:"createdAt"`
+	TenantID  int32     `json:"-"`
+}
+
+// adoptGroupName подставляет в песню имя группы, выбранной по GroupID.
+// Название, переданное вместе с ID, должно совпадать с этим именем без учета
+// регистра и пробелов, иначе неясно, какую группу имел в виду клиент.
+func adoptGroupName(song *database.Song, name string) error {
+	if song.GroupName != "" && NormalizeName(song.GroupName) != NormalizeName(name) {
+		return ErrGroupMismatch
+	}
+	song.GroupName = name
+	return nil
+}
+
+// Match проверяет, содержит ли имя или один из псевдонимов группы строку
+// query без учета регистра и пробелов. Пустой query подходит всем.
+func (g *Group) Match(query string) bool {
+	query = NormalizeName(query)
+	if strings.Contains(NormalizeName(g.Name), query) {
+		return true
+	}
+	for _, alias := range g.Aliases {
+		if strings.Contains(NormalizeName(alias), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupFromRow(row database.Group) *Group {
+	aliases := row.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return &Group{
+		ID:         row.ID,
+		Name:       row.Name,
+		Country:    row.Country.String,
+		FormedYear: row.FormedYear.Int32,
+		Aliases:    aliases,
+		CreatedAt:  row.CreatedAt,
+		TenantID:   row.TenantID,
+	}
+}
+
+func nullGroupFields(group *Group) (sql.NullString, sql.NullInt32) {
+	return sql.NullString{String: group.Country, Valid: group.Country != ""},
+		sql.NullInt32{Int32: group.FormedYear, Valid: group.FormedYear != 0}
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/logging"
+	"github.com/Kitrop/songGO-lib/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger = logging.For(logging.ComponentRepository)
+
+// InstrumentedRepository оборачивает каждую операцию хранилища в спан
+// трассировки и пишет ее в лог с длительностью и ID запроса из контекста.
+// Ожидаемые ошибки (песня не найдена, конфликт версий и т.п.) пишутся на
+// уровне debug и не отмечают спан как ошибочный, остальные — на уровне error.
+type InstrumentedRepository struct {
+	Repository
+}
+
+func NewInstrumentedRepository(repo Repository) *InstrumentedRepository {
+	return &InstrumentedRepository{Repository: repo}
+}
+
+// call описывает одну операцию хранилища.
+type call struct {
+	op    string
+	start time.Time
+	span  trace.Span
+}
+
+func (repo *InstrumentedRepository) begin(ctx context.Context, op string) (context.Context, *call) {
+	ctx, span := tracing.Start(ctx, "repository."+op, attribute.String("db.operation.name", op))
+	return ctx, &call{op: op, start: time.Now(), span: span}
+}
+
+func (c *call) end(ctx context.Context, err error, attrs ...slog.Attr) {
+	for _, a := range attrs {
+		c.span.SetAttributes(attribute.String(a.Key, a.Value.String()))
+	}
+	attrs = append(attrs, slog.String("op", c.op), slog.Duration("duration", time.Since(c.start)))
+
+	level, spanErr := slog.LevelDebug, error(nil)
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		if !expectedError(err) {
+			level, spanErr = slog.LevelError, err
+		}
+	}
+	tracing.End(c.span, spanErr)
+	logger.LogAttrs(ctx, level, "repository call", attrs...)
+}
+
+func expectedError(err error) bool {
+	return errors.Is(err, ErrSongNotFound) || errors.Is(err, ErrSongExists) ||
+		errors.Is(err, ErrVersionMismatch) || errors.Is(err, ErrBatchAborted) ||
+		errors.Is(err, ErrInvalidOperation) || errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrRevisionNotFound) || errors.Is(err, ErrDeletionRevision) ||
+		errors.Is(err, ErrGroupNotFound) || errors.Is(err, ErrGroupExists) ||
+		errors.Is(err, ErrGroupNotEmpty) || errors.Is(err, context.Canceled)
+}
+
+func (repo *InstrumentedRepository) GetAllSongs(ctx context.Context, filter SongFilter) ([]*database.Song, error) {
+	ctx, c := repo.begin(ctx, "GetAllSongs")
+	songs, err := repo.Repository.GetAllSongs(ctx, filter)
+	c.end(ctx, err, slog.Int("songs", len(songs)))
+	return songs, err
+}
+
+func (repo *InstrumentedRepository) StreamSongs(ctx context.Context, filter SongFilter, fn func(*database.Song) error) error {
+	ctx, c := repo.begin(ctx, "StreamSongs")
+	count := 0
+	err := repo.Repository.StreamSongs(ctx, filter, func(song *database.Song) error {
+		count++
+		return fn(song)
+	})
+	c.end(ctx, err, slog.Int("songs", count))
+	return err
+}
+
+func (repo *InstrumentedRepository) CountSongs(ctx context.Context) (int64, error) {
+	ctx, c := repo.begin(ctx, "CountSongs")
+	n, err := repo.Repository.CountSongs(ctx)
+	c.end(ctx, err)
+	return n, err
+}
+
+func (repo *InstrumentedRepository) GetSongByID(ctx context.Context, id int32) (*database.Song, error) {
+	ctx, c := repo.begin(ctx, "GetSongByID")
+	song, err := repo.Repository.GetSongByID(ctx, id)
+	c.end(ctx, err, slog.Int("song_id", int(id)))
+	return song, err
+}
+
+func (repo *InstrumentedRepository) GetSongByName(ctx context.Context, group, song string) (*database.Song, error) {
+	ctx, c := repo.begin(ctx, "GetSongByName")
+	found, err := repo.Repository.GetSongByName(ctx, group, song)
+	c.end(ctx, err)
+	return found, err
+}
+
+func (repo *InstrumentedRepository) CreateSong(ctx context.Context, song *database.Song) (*database.Song, error) {
+	ctx, c := repo.begin(ctx, "CreateSong")
+	created, err := repo.Repository.CreateSong(ctx, song)
+	attrs := []slog.Attr{}
+	if err == nil {
+		attrs = append(attrs, slog.Int("song_id", int(created.ID)))
+	}
+	c.end(ctx, err, attrs...)
+	return created, err
+}
+
+func (repo *InstrumentedRepository) UpdateSong(ctx context.Context, song *database.Song, expectedVersion int32) error {
+	ctx, c := repo.begin(ctx, "UpdateSong")
+	err := repo.Repository.UpdateSong(ctx, song, expectedVersion)
+	c.end(ctx, err, slog.Int("song_id", int(song.ID)))
+	return err
+}
+
+func (repo *InstrumentedRepository) UpsertSong(ctx context.Context, song *database.Song) (bool, error) {
+	ctx, c := repo.begin(ctx, "UpsertSong")
+	created, err := repo.Repository.UpsertSong(ctx, song)
+	c.end(ctx, err, slog.Bool("created", created))
+	return created, err
+}
+
+func (repo *InstrumentedRepository) DeleteSong(ctx context.Context, id int32, expectedVersion int32) error {
+	ctx, c := repo.begin(ctx, "DeleteSong")
+	err := repo.Repository.DeleteSong(ctx, id, expectedVersion)
+	c.end(ctx, err, slog.Int("song_id", int(id)))
+	return err
+}
+
+func (repo *InstrumentedRepository) ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	ctx, c := repo.begin(ctx, "ApplyBatch")
+	results, err := repo.Repository.ApplyBatch(ctx, ops, atomic)
+	c.end(ctx, err, slog.Int("operations", len(ops)), slog.Bool("atomic", atomic))
+	return results, err
+}
+
+func (repo *InstrumentedRepository) RestoreSongs(ctx context.Context, groups []*Group, songs []*database.Song) error {
+	ctx, c := repo.begin(ctx, "RestoreSongs")
+	err := repo.Repository.RestoreSongs(ctx, groups, songs)
+	c.end(ctx, err, slog.Int("groups", len(groups)), slog.Int("songs", len(songs)))
+	return err
+}
+
+func (repo *InstrumentedRepository) ListRevisions(ctx context.Context, id int32) ([]*Revision, error) {
+	ctx, c := repo.begin(ctx, "ListRevisions")
+	revisions, err := repo.Repository.ListRevisions(ctx, id)
+	c.end(ctx, err, slog.Int("song_id", int(id)), slog.Int("revisions", len(revisions)))
+	return revisions, err
+}
+
+func (repo *InstrumentedRepository) RevertSong(ctx context.Context, id, rev int32, expectedVersion int32) (*database.Song, error) {
+	ctx, c := repo.begin(ctx, "RevertSong")
+	song, err := repo.Repository.RevertSong(ctx, id, rev, expectedVersion)
+	c.end(ctx, err, slog.Int("song_id", int(id)), slog.Int("revision", int(rev)))
+	return song, err
+}
+
+func (repo *InstrumentedRepository) ListDeleted(ctx context.Context) ([]*database.Song, error) {
+	ctx, c := repo.begin(ctx, "ListDeleted")
+	songs, err := repo.Repository.ListDeleted(ctx)
+	c.end(ctx, err, slog.Int("songs", len(songs)))
+	return songs, err
+}
+
+func (repo *InstrumentedRepository) UndeleteSong(ctx context.Context, id int32) (*database.Song, error) {
+	ctx, c := repo.begin(ctx, "UndeleteSong")
+	song, err := repo.Repository.UndeleteSong(ctx, id)
+	c.end(ctx, err, slog.Int("song_id", int(id)))
+	return song, err
+}
+
+func (repo *InstrumentedRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, c := repo.begin(ctx, "PurgeDeleted")
+	purged, err := repo.Repository.PurgeDeleted(ctx, before)
+	c.end(ctx, err, slog.Time("before", before), slog.Int64("purged", purged))
+	return purged, err
+}
+
+func (repo *InstrumentedRepository) ListGroups(ctx context.Context, query string) ([]*Group, error) {
+	ctx, c := repo.begin(ctx, "ListGroups")
+	groups, err := repo.Repository.ListGroups(ctx, query)
+	c.end(ctx, err, slog.Int("groups", len(groups)))
+	return groups, err
+}
+
+func (repo *InstrumentedRepository) GetGroup(ctx context.Context, id int32) (*Group, error) {
+	ctx, c := repo.begin(ctx, "GetGroup")
+	group, err := repo.Repository.GetGroup(ctx, id)
+	c.end(ctx, err, slog.Int("group_id", int(id)))
+	return group, err
+}
+
+func (repo *InstrumentedRepository) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	ctx, c := repo.begin(ctx, "CreateGroup")
+	created, err := repo.Repository.CreateGroup(ctx, group)
+	attrs := []slog.Attr{}
+	if err == nil {
+		attrs = append(attrs, slog.Int("group_id", int(created.ID)))
+	}
+	c.end(ctx, err, attrs...)
+	return created, err
+}
+
+func (repo *InstrumentedRepository) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+	ctx, c := repo.begin(ctx, "UpdateGroup")
+	updated, err := repo.Repository.UpdateGroup(ctx, group)
+	c.end(ctx, err, slog.Int("group_id", int(group.ID)))
+	return updated, err
+}
+
+func (repo *InstrumentedRepository) DeleteGroup(ctx context.Context, id int32) error {
+	ctx, c := repo.begin(ctx, "DeleteGroup")
+	err := repo.Repository.DeleteGroup(ctx, id)
+	c.end(ctx, err, slog.Int("group_id", int(id)))
+	return err
+}
@@ -0,0 +1,677 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/tenant"
+	"github.com/lib/pq"
+)
+
+// Имя уникального индекса по клиенту и нормализованной паре группа + название.
+const songsGroupSongKey = "songs_group_song_key"
+
+// Имя уникального индекса по клиенту и нормализованному имени группы.
+const groupsNameKey = "groups_name_key"
+
+// Имя внешнего ключа песни на ее группу.
+const songsGroupFKey = "songs_group_id_fkey"
+
+// SQLSTATE, с которым триггер songs_tenant_quota отклоняет вставку сверх квоты.
+const quotaExceededCode = "SG001"
+
+// PostgresRepository хранит песни в PostgreSQL через сгенерированные sqlc запросы.
+type PostgresRepository struct {
+	db      *sql.DB
+	queries *database.Queries
+}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db, queries: database.New(db)}
+}
+
+// Получить список песен, подходящих под фильтр
+func (repo *PostgresRepository) GetAllSongs(ctx context.Context, filter SongFilter) ([]*database.Song, error) {
+	rows, err := repo.queries.GetSongs(ctx, songsParams(ctx, filter))
+	if err != nil {
+		return nil, err
+	}
+
+	songs := make([]*database.Song, 0, len(rows))
+	for i := range rows {
+		songs = append(songs, &rows[i])
+	}
+	return songs, nil
+}
+
+// Передать песни, подходящие под фильтр, по одной
+func (repo *PostgresRepository) StreamSongs(ctx context.Context, filter SongFilter, fn func(*database.Song) error) error {
+	return repo.queries.StreamSongs(ctx, songsParams(ctx, filter), fn)
+}
+
+func songsParams(ctx context.Context, filter SongFilter) database.GetSongsParams {
+	return database.GetSongsParams{
+		TenantID:       tenant.ID(ctx),
+		GroupName:      sql.NullString{String: filter.Group, Valid: filter.Group != ""},
+		GroupID:        sql.NullInt32{Int32: filter.GroupID, Valid: filter.GroupID != 0},
+		Song:           sql.NullString{String: filter.Song, Valid: filter.Song != ""},
+		ReleasedAfter:  sql.NullTime{Time: filter.ReleasedAfter, Valid: !filter.ReleasedAfter.IsZero()},
+		ReleasedBefore: sql.NullTime{Time: filter.ReleasedBefore, Valid: !filter.ReleasedBefore.IsZero()},
+		Year:           sql.NullInt32{Int32: int32(filter.Year), Valid: filter.Year != 0},
+	}
+}
+
+// Посчитать песни
+func (repo *PostgresRepository) CountSongs(ctx context.Context) (int64, error) {
+	return repo.queries.CountSongs(ctx, tenant.ID(ctx))
+}
+
+// Получить песню по ID
+func (repo *PostgresRepository) GetSongByID(ctx context.Context, id int32) (*database.Song, error) {
+	song, err := repo.queries.GetSongByID(ctx, database.GetSongByIDParams{ID: id, TenantID: tenant.ID(ctx)})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSongNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &song, nil
+}
+
+// Получить песню по группе и названию
+func (repo *PostgresRepository) GetSongByName(ctx context.Context, group, song string) (*database.Song, error) {
+	found, err := repo.queries.GetSongByName(ctx, database.GetSongByNameParams{
+		TenantID:  tenant.ID(ctx),
+		GroupName: group,
+		Song:      song,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSongNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// Добавить новую песню
+func (repo *PostgresRepository) CreateSong(ctx context.Context, song *database.Song) (*database.Song, error) {
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		if err := lockSongGroup(ctx, q, song); err != nil {
+			return err
+		}
+		created, err := q.CreateSong(ctx, database.CreateSongParams{
+			GroupName:            song.GroupName,
+			Song:                 song.Song,
+			ReleaseDate:          song.ReleaseDate,
+			ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+			SongText:             song.SongText,
+			Link:                 song.Link,
+			TenantID:             tenant.ID(ctx),
+		})
+		if err != nil {
+			return translateError(err)
+		}
+		*song = created
+		return recordRevision(ctx, q, RevisionCreate, song, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return song, nil
+}
+
+// Обновить песню
+func (repo *PostgresRepository) UpdateSong(ctx context.Context, song *database.Song, expectedVersion int32) error {
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		if err := lockSongGroup(ctx, q, song); err != nil {
+			return err
+		}
+		updated, err := q.UpdateSong(ctx, updateParams(ctx, song, expectedVersion))
+		if err != nil {
+			return translateError(err)
+		}
+		*song = updated
+		return recordRevision(ctx, q, RevisionUpdate, song, 0)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return repo.missingOrStale(ctx, song.ID)
+	}
+	return err
+}
+
+// lockSongGroup заменяет название группы в песне именем группы GroupID, если
+// он задан (расходящееся с ним название — ErrGroupMismatch): триггер
+// resolve_song_group находит группу по этому имени. Группа блокируется до конца транзакции, чтобы ее не
+// переименовали в это время.
+func lockSongGroup(ctx context.Context, q *database.Queries, song *database.Song) error {
+	if song.GroupID == 0 {
+		return nil
+	}
+	group, err := q.LockGroup(ctx, database.LockGroupParams{ID: song.GroupID, TenantID: tenant.ID(ctx)})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return adoptGroupName(song, group.Name)
+}
+
+func updateParams(ctx context.Context, song *database.Song, expectedVersion int32) database.UpdateSongParams {
+	return database.UpdateSongParams{
+		ID:                   song.ID,
+		GroupName:            song.GroupName,
+		Song:                 song.Song,
+		ReleaseDate:          song.ReleaseDate,
+		ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+		SongText:             song.SongText,
+		Link:                 song.Link,
+		TenantID:             tenant.ID(ctx),
+		ExpectedVersion:      versionParam(expectedVersion),
+	}
+}
+
+// Создать или обновить песню по группе и названию
+func (repo *PostgresRepository) UpsertSong(ctx context.Context, song *database.Song) (bool, error) {
+	var created bool
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		row, err := q.UpsertSong(ctx, database.UpsertSongParams{
+			GroupName:            song.GroupName,
+			Song:                 song.Song,
+			ReleaseDate:          song.ReleaseDate,
+			ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+			SongText:             song.SongText,
+			Link:                 song.Link,
+			TenantID:             tenant.ID(ctx),
+		})
+		if err != nil {
+			return translateError(err)
+		}
+		*song = database.Song{
+			ID:                   row.ID,
+			GroupName:            row.GroupName,
+			Song:                 row.Song,
+			ReleaseDate:          row.ReleaseDate,
+			SongText:             row.SongText,
+			Link:                 row.Link,
+			ReleaseDatePrecision: row.ReleaseDatePrecision,
+			Version:              row.Version,
+			TenantID:             row.TenantID,
+			GroupID:              row.GroupID,
+		}
+		created = row.Inserted
+		action := RevisionUpdate
+		if created {
+			action = RevisionCreate
+		}
+		return recordRevision(ctx, q, action, song, 0)
+	})
+	return created, err
+}
+
+// Переместить песню в корзину
+func (repo *PostgresRepository) DeleteSong(ctx context.Context, id int32, expectedVersion int32) error {
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		deleted, err := q.DeleteSong(ctx, database.DeleteSongParams{
+			ID:              id,
+			TenantID:        tenant.ID(ctx),
+			ExpectedVersion: versionParam(expectedVersion),
+		})
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, q, RevisionDelete, &deleted, 0)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return repo.missingOrStale(ctx, id)
+	}
+	return err
+}
+
+// Получить песни из корзины
+func (repo *PostgresRepository) ListDeleted(ctx context.Context) ([]*database.Song, error) {
+	rows, err := repo.queries.ListTrash(ctx, tenant.ID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]*database.Song, 0, len(rows))
+	for i := range rows {
+		songs = append(songs, &rows[i])
+	}
+	return songs, nil
+}
+
+// Вернуть песню из корзины
+func (repo *PostgresRepository) UndeleteSong(ctx context.Context, id int32) (*database.Song, error) {
+	var song database.Song
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		trashed, err := q.GetTrashedSong(ctx, database.GetTrashedSongParams{ID: id, TenantID: tenant.ID(ctx)})
+		if err != nil {
+			return err
+		}
+		song, err = q.UndeleteSong(ctx, undeleteParams(ctx, &trashed))
+		if err != nil {
+			return translateError(err)
+		}
+		return recordRevision(ctx, q, RevisionRestore, &song, 0)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSongNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &song, nil
+}
+
+func undeleteParams(ctx context.Context, song *database.Song) database.UndeleteSongParams {
+	return database.UndeleteSongParams{
+		ID:                   song.ID,
+		TenantID:             tenant.ID(ctx),
+		GroupName:            song.GroupName,
+		Song:                 song.Song,
+		ReleaseDate:          song.ReleaseDate,
+		ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+		SongText:             song.SongText,
+		Link:                 song.Link,
+	}
+}
+
+// Окончательно удалить песни, давно попавшие в корзину
+func (repo *PostgresRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return repo.queries.PurgeDeletedSongs(ctx, sql.NullTime{Time: before, Valid: true})
+}
+
+// Получить историю песни
+func (repo *PostgresRepository) ListRevisions(ctx context.Context, id int32) ([]*Revision, error) {
+	rows, err := repo.queries.ListSongRevisions(ctx, database.ListSongRevisionsParams{SongID: id, TenantID: tenant.ID(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrSongNotFound
+	}
+	revisions := make([]*Revision, 0, len(rows))
+	for _, row := range rows {
+		revisions = append(revisions, revisionFromRow(row))
+	}
+	return revisions, nil
+}
+
+// Откатить песню к ревизии
+func (repo *PostgresRepository) RevertSong(ctx context.Context, id, rev int32, expectedVersion int32) (*database.Song, error) {
+	var song *database.Song
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		rows, err := q.ListSongRevisions(ctx, database.ListSongRevisionsParams{SongID: id, TenantID: tenant.ID(ctx)})
+		if err != nil {
+			return err
+		}
+		revisions := make([]*Revision, 0, len(rows))
+		for _, row := range rows {
+			revisions = append(revisions, revisionFromRow(row))
+		}
+		target, err := revertTarget(revisions, rev)
+		if err != nil {
+			return err
+		}
+		song = &target.Song
+
+		_, err = q.GetSongByID(ctx, database.GetSongByIDParams{ID: id, TenantID: tenant.ID(ctx)})
+		if errors.Is(err, sql.ErrNoRows) && expectedVersion != 0 {
+			return ErrVersionMismatch
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = q.GetTrashedSong(ctx, database.GetTrashedSongParams{ID: id, TenantID: tenant.ID(ctx)})
+			if err == nil {
+				// Песня в корзине: возвращаем ее с полями из ревизии
+				*song, err = q.UndeleteSong(ctx, undeleteParams(ctx, song))
+				if err != nil {
+					return translateError(err)
+				}
+				return recordRevision(ctx, q, RevisionRevert, song, rev)
+			}
+		}
+		switch {
+		case err == nil:
+			// Песня есть: обычное изменение с проверкой версии
+			*song, err = q.UpdateSong(ctx, updateParams(ctx, song, expectedVersion))
+			if err != nil {
+				return translateError(err)
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			// Песня удалена окончательно: создаем ее заново с тем же ID
+			*song, err = q.RestoreSong(ctx, database.RestoreSongParams{
+				ID:                   id,
+				GroupName:            song.GroupName,
+				Song:                 song.Song,
+				ReleaseDate:          song.ReleaseDate,
+				ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+				SongText:             song.SongText,
+				Link:                 song.Link,
+				Version:              revisions[len(revisions)-1].Song.Version + 1,
+				TenantID:             tenant.ID(ctx),
+			})
+			if err != nil {
+				return translateError(err)
+			}
+		default:
+			return err
+		}
+		return recordRevision(ctx, q, RevisionRevert, song, rev)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repo.missingOrStale(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return song, nil
+}
+
+// Выполнить пакет операций в одной транзакции
+func (repo *PostgresRepository) ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	txRepo := &PostgresRepository{queries: repo.queries.WithTx(tx)}
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		// В режиме best-effort ошибка одной операции не должна прерывать транзакцию
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_item"); err != nil {
+				return nil, err
+			}
+		}
+
+		results[i] = applyOperation(ctx, txRepo, op)
+		if results[i].Err == nil {
+			continue
+		}
+		if atomic {
+			abortBatch(results, i)
+			return results, nil
+		}
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_item"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Заменить все песни клиента, сохранив их ID и версии
+func (repo *PostgresRepository) RestoreSongs(ctx context.Context, groups []*Group, songs []*database.Song) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := repo.queries.WithTx(tx)
+	replaced, err := queries.DeleteAllSongs(ctx, tenant.ID(ctx))
+	if err != nil {
+		return err
+	}
+	if groups != nil {
+		if err := restoreGroups(ctx, queries, groups); err != nil {
+			return err
+		}
+	}
+	kept := make(map[int32]bool, len(songs))
+	for _, song := range songs {
+		restored, err := queries.RestoreSong(ctx, database.RestoreSongParams{
+			ID:                   song.ID,
+			GroupName:            song.GroupName,
+			Song:                 song.Song,
+			ReleaseDate:          song.ReleaseDate,
+			ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+			SongText:             song.SongText,
+			Link:                 song.Link,
+			Version:              song.Version,
+			TenantID:             tenant.ID(ctx),
+		})
+		if err != nil {
+			return fmt.Errorf("song %d: %w", song.ID, translateError(err))
+		}
+		if err := recordRevision(ctx, queries, RevisionRestore, &restored, 0); err != nil {
+			return err
+		}
+		kept[song.ID] = true
+	}
+	// История песен, которых нет в архиве, заканчивается удалением; у песен
+	// из корзины оно уже записано
+	for i := range replaced {
+		if kept[replaced[i].ID] || replaced[i].DeletedAt.Valid {
+			continue
+		}
+		if err := recordRevision(ctx, queries, RevisionDelete, &replaced[i], 0); err != nil {
+			return err
+		}
+	}
+	// Новые песни должны получать ID после восстановленных
+	if err := queries.ResetSongsIDSequence(ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// restoreGroups заменяет группы клиента переданными с прежними ID. Песни
+// клиента к этому моменту уже удалены.
+func restoreGroups(ctx context.Context, q *database.Queries, groups []*Group) error {
+	if err := q.DeleteAllGroups(ctx, tenant.ID(ctx)); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		country, formedYear := nullGroupFields(group)
+		err := q.RestoreGroup(ctx, database.RestoreGroupParams{
+			ID:         group.ID,
+			TenantID:   tenant.ID(ctx),
+			Name:       group.Name,
+			Country:    country,
+			FormedYear: formedYear,
+			Aliases:    group.Aliases,
+			CreatedAt:  group.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("group %d: %w", group.ID, translateError(err))
+		}
+	}
+	return q.ResetGroupsIDSequence(ctx)
+}
+
+// Получить группы
+func (repo *PostgresRepository) ListGroups(ctx context.Context, query string) ([]*Group, error) {
+	rows, err := repo.queries.ListGroups(ctx, database.ListGroupsParams{TenantID: tenant.ID(ctx), Query: query})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]*Group, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, groupFromRow(row))
+	}
+	return groups, nil
+}
+
+// Получить группу по ID
+func (repo *PostgresRepository) GetGroup(ctx context.Context, id int32) (*Group, error) {
+	row, err := repo.queries.GetGroup(ctx, database.GetGroupParams{ID: id, TenantID: tenant.ID(ctx)})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return groupFromRow(row), nil
+}
+
+// Добавить группу
+func (repo *PostgresRepository) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	country, formedYear := nullGroupFields(group)
+	row, err := repo.queries.CreateGroup(ctx, database.CreateGroupParams{
+		TenantID:   tenant.ID(ctx),
+		Name:       group.Name,
+		Country:    country,
+		FormedYear: formedYear,
+		Aliases:    group.Aliases,
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return groupFromRow(row), nil
+}
+
+// Обновить группу и переименовать ее песни
+func (repo *PostgresRepository) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+	var updated *Group
+	err := repo.inTx(ctx, func(q *database.Queries) error {
+		old, err := q.GetGroup(ctx, database.GetGroupParams{ID: group.ID, TenantID: tenant.ID(ctx)})
+		if err != nil {
+			return err
+		}
+		country, formedYear := nullGroupFields(group)
+		row, err := q.UpdateGroup(ctx, database.UpdateGroupParams{
+			ID:         group.ID,
+			TenantID:   tenant.ID(ctx),
+			Name:       group.Name,
+			Country:    country,
+			FormedYear: formedYear,
+			Aliases:    group.Aliases,
+		})
+		if err != nil {
+			return translateError(err)
+		}
+		updated = groupFromRow(row)
+		if row.Name == old.Name {
+			return nil
+		}
+
+		songs, err := q.RenameGroupSongs(ctx, database.RenameGroupSongsParams{Name: row.Name, GroupID: row.ID})
+		if err != nil {
+			return err
+		}
+		for i := range songs {
+			if songs[i].DeletedAt.Valid {
+				continue
+			}
+			if err := recordRevision(ctx, q, RevisionUpdate, &songs[i], 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Удалить группу без песен
+func (repo *PostgresRepository) DeleteGroup(ctx context.Context, id int32) error {
+	deleted, err := repo.queries.DeleteGroup(ctx, database.DeleteGroupParams{ID: id, TenantID: tenant.ID(ctx)})
+	if err != nil {
+		return translateError(err)
+	}
+	if deleted == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// inTx выполняет fn в транзакции, чтобы изменение песни и его ревизия
+// сохранились вместе. Репозиторий пакета в ApplyBatch уже работает в
+// транзакции, и fn выполняется в ней.
+func (repo *PostgresRepository) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	if repo.db == nil {
+		return fn(repo.queries)
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(repo.queries.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordRevision записывает снимок песни в историю.
+func recordRevision(ctx context.Context, q *database.Queries, action string, song *database.Song, revertedFrom int32) error {
+	by := actor(ctx)
+	return q.CreateSongRevision(ctx, database.CreateSongRevisionParams{
+		TenantID:             song.TenantID,
+		SongID:               song.ID,
+		Action:               action,
+		Actor:                sql.NullString{String: by, Valid: by != ""},
+		RevertedFrom:         sql.NullInt32{Int32: revertedFrom, Valid: revertedFrom != 0},
+		GroupName:            song.GroupName,
+		Song:                 song.Song,
+		ReleaseDate:          song.ReleaseDate,
+		ReleaseDatePrecision: precisionOrDefault(song.ReleaseDatePrecision),
+		SongText:             song.SongText,
+		Link:                 song.Link,
+		Version:              song.Version,
+		GroupID:              sql.NullInt32{Int32: song.GroupID, Valid: song.GroupID != 0},
+	})
+}
+
+// missingOrStale определяет, почему условное изменение не затронуло ни одной строки:
+// песни нет или ее версия не совпала с ожидаемой.
+func (repo *PostgresRepository) missingOrStale(ctx context.Context, id int32) error {
+	_, err := repo.queries.GetSongByID(ctx, database.GetSongByIDParams{ID: id, TenantID: tenant.ID(ctx)})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSongNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// translateError превращает нарушение уникальности группы и названия в ErrSongExists,
+// уникальности имени группы — в ErrGroupExists, ссылку песен на удаляемую
+// группу — в ErrGroupNotEmpty, а ошибку триггера квоты — в ErrQuotaExceeded.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch {
+	case pqErr.Code == "23505" && pqErr.Constraint == songsGroupSongKey:
+		return ErrSongExists
+	case pqErr.Code == "23505" && pqErr.Constraint == groupsNameKey:
+		return ErrGroupExists
+	case pqErr.Code == "23503" && pqErr.Constraint == songsGroupFKey:
+		// Удаление группы, на которую ссылаются песни
+		return ErrGroupNotEmpty
+	case pqErr.Code == quotaExceededCode:
+		return ErrQuotaExceeded
+	}
+	return err
+}
+
+func versionParam(version int32) sql.NullInt32 {
+	return sql.NullInt32{Int32: version, Valid: version != 0}
+}
+
+func precisionOrDefault(p database.DatePrecision) database.DatePrecision {
+	if p == "" {
+		return database.DatePrecisionDay
+	}
+	return p
+}
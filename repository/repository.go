@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+// ErrSongNotFound возвращается, если песни с указанным ID нет в хранилище.
+var ErrSongNotFound = errors.New("song not found")
+
+// ErrSongExists возвращается, если песня с такой же парой группа + название
+// (без учета регистра и пробелов) уже есть в хранилище.
+var ErrSongExists = errors.New("song already exists")
+
+// ErrVersionMismatch возвращается, если версия песни в хранилище отличается
+// от ожидаемой клиентом (оптимистичная блокировка).
+var ErrVersionMismatch = errors.New("song version mismatch")
+
+// ErrQuotaExceeded возвращается, если у клиента уже столько песен, сколько
+// позволяет его квота.
+var ErrQuotaExceeded = errors.New("tenant song quota exceeded")
+
+// Repository описывает хранилище песен и их групп. Реализации: SongRepository
+// (в памяти) и PostgresRepository. Все методы работают с песнями клиента из
+// контекста (tenant.ID): песни других клиентов для них не существуют. Каждое
+// создание, изменение и удаление песни записывается в историю ревизий
+// атомарно с ним. Песня попадает в группу по названию группы без учета
+// регистра и пробелов; новая группа создается автоматически, а название
+// группы в песне заменяется именем группы.
+type Repository interface {
+	GetAllSongs(ctx context.Context, filter SongFilter) ([]*database.Song, error)
+	// StreamSongs передает песни, подходящие под фильтр, в fn по одной в порядке ID,
+	// не собирая их в память. Ошибка fn прерывает обход и возвращается как есть.
+	// Песня, переданная в fn, действительна только до возврата из fn.
+	StreamSongs(ctx context.Context, filter SongFilter, fn func(*database.Song) error) error
+	// CountSongs возвращает число песен клиента.
+	CountSongs(ctx context.Context) (int64, error)
+	GetSongByID(ctx context.Context, id int32) (*database.Song, error)
+	GetSongByName(ctx context.Context, group, song string) (*database.Song, error)
+	// CreateSong добавляет песню. Если задан GroupID, песня попадает в эту
+	// группу; если такой группы нет — ErrGroupNotFound, если GroupID задан
+	// вместе с другим названием группы — ErrGroupMismatch.
+	CreateSong(ctx context.Context, song *database.Song) (*database.Song, error)
+	// UpdateSong сохраняет песню и увеличивает ее версию. Если expectedVersion
+	// не равна 0, песня обновляется, только если ее текущая версия совпадает.
+	// GroupID работает как в CreateSong.
+	UpdateSong(ctx context.Context, song *database.Song, expectedVersion int32) error
+	// UpsertSong создает песню или обновляет дату выпуска, текст и ссылку
+	// существующей песни с той же группой и названием. created сообщает,
+	// была ли песня создана.
+	UpsertSong(ctx context.Context, song *database.Song) (created bool, err error)
+	// DeleteSong перемещает песню в корзину; expectedVersion работает как в
+	// UpdateSong. Песня в корзине не видна остальным методам и не занимает
+	// группу и название.
+	DeleteSong(ctx context.Context, id int32, expectedVersion int32) error
+	// ListDeleted возвращает песни клиента из корзины, последние удаленные первыми.
+	ListDeleted(ctx context.Context) ([]*database.Song, error)
+	// UndeleteSong возвращает песню из корзины и увеличивает ее версию;
+	// ErrSongNotFound, если в корзине ее нет.
+	UndeleteSong(ctx context.Context, id int32) (*database.Song, error)
+	// PurgeDeleted окончательно удаляет песни всех клиентов, попавшие в
+	// корзину раньше before, и возвращает их число. История песен остается.
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	// ApplyBatch выполняет операции в одной транзакции. В атомарном режиме первая
+	// ошибка отменяет весь пакет, иначе ошибочные операции пропускаются.
+	// Возвращаемая ошибка относится к самой транзакции, а не к отдельным операциям.
+	ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+	// RestoreSongs заменяет все песни клиента переданными, сохраняя их ID и версии.
+	// Если groups не nil, группы клиента тоже заменяются ими с прежними ID до
+	// восстановления песен; иначе группы остаются, а песни находят их по
+	// названию. Замена атомарна: при ошибке хранилище остается прежним. Каждая
+	// восстановленная песня получает ревизию restore, а песня, которой нет среди
+	// переданных, — ревизию delete.
+	RestoreSongs(ctx context.Context, groups []*Group, songs []*database.Song) error
+	// ListRevisions возвращает историю песни по возрастанию номера ревизии,
+	// в том числе уже удаленной песни; ErrSongNotFound, если истории нет.
+	ListRevisions(ctx context.Context, id int32) ([]*Revision, error)
+	// RevertSong возвращает песню к состоянию ревизии rev и записывает это
+	// как новую ревизию. Песня из корзины восстанавливается, а окончательно
+	// удаленная создается заново с прежним ID.
+	// expectedVersion работает как в UpdateSong.
+	RevertSong(ctx context.Context, id, rev int32, expectedVersion int32) (*database.Song, error)
+	// ListGroups возвращает группы клиента по алфавиту. Непустой query
+	// оставляет группы, имя или псевдоним которых его содержит (см. Group.Match).
+	ListGroups(ctx context.Context, query string) ([]*Group, error)
+	GetGroup(ctx context.Context, id int32) (*Group, error)
+	// CreateGroup добавляет группу; ErrGroupExists, если группа с таким именем есть.
+	CreateGroup(ctx context.Context, group *Group) (*Group, error)
+	// UpdateGroup заменяет данные группы. При переименовании новое имя
+	// получают все песни группы, в том числе в корзине; у остальных песен
+	// увеличивается версия и записывается ревизия.
+	UpdateGroup(ctx context.Context, group *Group) (*Group, error)
+	// DeleteGroup удаляет группу; ErrGroupNotEmpty, если у нее есть песни.
+	DeleteGroup(ctx context.Context, id int32) error
+}
+
+// NormalizeName приводит название группы или песни к виду, по которому
+// проверяется уникальность: нижний регистр, без крайних и повторяющихся пробелов.
+// Должна совпадать с SQL-функцией normalize_name.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// SongFilter задает условия отбора песен в списке. Пустые поля не учитываются.
+// Границы по дате выпуска включительные и сравниваются с первым днем периода,
+// который покрывает дата песни.
+type SongFilter struct {
+	Group          string
+	GroupID        int32
+	Song           string
+	ReleasedAfter  time.Time
+	ReleasedBefore time.Time
+	Year           int
+}
+
+// Match проверяет, подходит ли песня под фильтр.
+func (f SongFilter) Match(song *database.Song) bool {
+	if f.Group != "" && song.GroupName != f.Group {
+		return false
+	}
+	if f.GroupID != 0 && song.GroupID != f.GroupID {
+		return false
+	}
+	if f.Song != "" && song.Song != f.Song {
+		return false
+	}
+	if f.ReleasedAfter.IsZero() && f.ReleasedBefore.IsZero() && f.Year == 0 {
+		return true
+	}
+	if !song.ReleaseDate.Valid {
+		return false
+	}
+	released := song.ReleaseDate.Time
+	if !f.ReleasedAfter.IsZero() && released.Before(f.ReleasedAfter) {
+		return false
+	}
+	if !f.ReleasedBefore.IsZero() && released.After(f.ReleasedBefore) {
+		return false
+	}
+	if f.Year != 0 && released.Year() != f.Year {
+		return false
+	}
+	return true
+}
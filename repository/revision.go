@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+// ErrRevisionNotFound возвращается, если у песни нет ревизии с таким номером.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ErrDeletionRevision возвращается при попытке откатить песню к ревизии,
+// которая записывает ее удаление.
+var ErrDeletionRevision = errors.New("cannot revert to a revision that records a deletion")
+
+// Действия, которые записываются в историю песни.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+)
+
+// Revision — запись истории песни: полный снимок песни после действия,
+// а для удаления — состояние, в котором песня попала в корзину.
+type Revision struct {
+	// Revision — номер ревизии, у каждой песни нумерация начинается с 1
+	Revision int32  `json:"revision" example:"3"`
+	SongID   int32  `json:"songId" example:"1"`
+	Action   string `json:"action" example:"update" enums:"create,update,delete,restore,revert"`
+	// Actor — имя API-ключа или sub токена; пусто для команд CLI и запросов без аутентификации
+	Actor string `json:"actor,omitempty" example:"partner-x"`
+	// RevertedFrom — номер ревизии, к которой откатили песню
+	RevertedFrom int32         `json:"revertedFrom,omitempty" example:"1"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	Song         database.Song `json:"song"`
+}
+
+// actor возвращает имя, под которым изменение записывается в историю.
+func actor(ctx context.Context) string {
+	if id := auth.FromContext(ctx); id != nil {
+		return id.Subject
+	}
+	return ""
+}
+
+func revisionFromRow(row database.SongRevision) *Revision {
+	return &Revision{
+		Revision:     row.Revision,
+		SongID:       row.SongID,
+		Action:       row.Action,
+		Actor:        row.Actor.String,
+		RevertedFrom: row.RevertedFrom.Int32,
+		CreatedAt:    row.CreatedAt,
+		Song: database.Song{
+			ID:                   row.SongID,
+			GroupName:            row.GroupName,
+			Song:                 row.Song,
+			ReleaseDate:          row.ReleaseDate,
+			SongText:             row.SongText,
+			Link:                 row.Link,
+			ReleaseDatePrecision: row.ReleaseDatePrecision,
+			Version:              row.Version,
+			TenantID:             row.TenantID,
+			GroupID:              row.GroupID.Int32,
+		},
+	}
+}
+
+// revertTarget находит ревизию rev, к которой можно откатить песню, и
+// возвращает ее копию.
+func revertTarget(revisions []*Revision, rev int32) (*Revision, error) {
+	if len(revisions) == 0 {
+		return nil, ErrSongNotFound
+	}
+	for _, r := range revisions {
+		if r.Revision != rev {
+			continue
+		}
+		if r.Action == RevisionDelete {
+			return nil, ErrDeletionRevision
+		}
+		target := *r
+		return &target, nil
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// FieldChange — изменение поля песни между двумя ревизиями. Отсутствующее
+// значение — null.
+type FieldChange struct {
+	Field string  `json:"field" example:"songText"`
+	From  *string `json:"from"`
+	To    *string `json:"to"`
+	// Lines — построчная разница текста песни: строки с префиксом "-" удалены,
+	// с "+" добавлены, с " " не изменились
+	Lines []string `json:"lines,omitempty"`
+}
+
+// Больше строк текст сравнивается целиком: построчное сравнение квадратично.
+const maxLineDiffCells = 1 << 20
+
+// DiffSongs возвращает изменившиеся поля песни в порядке groupName, song,
+// releaseDate, songText, link.
+func DiffSongs(from, to *database.Song) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field string, a, b *string) *FieldChange {
+		if (a == nil) == (b == nil) && (a == nil || *a == *b) {
+			return nil
+		}
+		changes = append(changes, FieldChange{Field: field, From: a, To: b})
+		return &changes[len(changes)-1]
+	}
+	add("groupName", &from.GroupName, &to.GroupName)
+	add("song", &from.Song, &to.Song)
+	add("releaseDate", releaseString(from), releaseString(to))
+	if c := add("songText", nullString(from.SongText.String, from.SongText.Valid), nullString(to.SongText.String, to.SongText.Valid)); c != nil {
+		c.Lines = diffLines(from.SongText.String, to.SongText.String)
+	}
+	add("link", nullString(from.Link.String, from.Link.Valid), nullString(to.Link.String, to.Link.Valid))
+	return changes
+}
+
+func releaseString(song *database.Song) *string {
+	d := song.Release()
+	return nullString(d.String(), d.Valid)
+}
+
+func nullString(s string, valid bool) *string {
+	if !valid {
+		return nil
+	}
+	return &s
+}
+
+// diffLines сравнивает тексты построчно по наибольшей общей подпоследовательности.
+func diffLines(a, b string) []string {
+	x, y := splitLines(a), splitLines(b)
+	if len(x)*len(y) > maxLineDiffCells {
+		lines := make([]string, 0, len(x)+len(y))
+		for _, l := range x {
+			lines = append(lines, "-"+l)
+		}
+		for _, l := range y {
+			lines = append(lines, "+"+l)
+		}
+		return lines
+	}
+
+	// lcs[i][j] — длина общей подпоследовательности x[i:] и y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]string, 0, max(len(x), len(y)))
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, " "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+x[i])
+			i++
+		default:
+			lines = append(lines, "+"+y[j])
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/database"
+)
+
+func text(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func revisionActions(t *testing.T, repo Repository, ctx context.Context, id int32) []string {
+	t.Helper()
+	revisions, err := repo.ListRevisions(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, len(revisions))
+	for i, r := range revisions {
+		if r.Revision != int32(i+1) {
+			t.Fatalf("revision %d has number %d", i+1, r.Revision)
+		}
+		actions[i] = r.Action
+	}
+	return actions
+}
+
+func TestMemoryRepositoryRecordsRevisions(t *testing.T) {
+	repo := NewSongRepository()
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Subject: "partner-x"})
+
+	song, _ := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Uprising", SongText: text("a\nb")})
+	if err := repo.UpdateSong(ctx, &database.Song{ID: song.ID, GroupName: "Muse", Song: "Uprising", SongText: text("a\nc")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteSong(ctx, song.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{RevisionCreate, RevisionUpdate, RevisionDelete}
+	if got := revisionActions(t, repo, ctx, song.ID); !slices.Equal(got, want) {
+		t.Fatalf("actions = %v, want %v", got, want)
+	}
+	revisions, _ := repo.ListRevisions(ctx, song.ID)
+	if revisions[0].Actor != "partner-x" {
+		t.Errorf("actor = %q, want partner-x", revisions[0].Actor)
+	}
+	if revisions[0].Song.SongText.String != "a\nb" || revisions[1].Song.SongText.String != "a\nc" {
+		t.Errorf("revisions do not keep song snapshots: %q, %q", revisions[0].Song.SongText.String, revisions[1].Song.SongText.String)
+	}
+
+	changes := DiffSongs(&revisions[0].Song, &revisions[1].Song)
+	if len(changes) != 1 || changes[0].Field != "songText" {
+		t.Fatalf("diff = %+v, want a songText change", changes)
+	}
+	if wantLines := []string{" a", "-b", "+c"}; !slices.Equal(changes[0].Lines, wantLines) {
+		t.Errorf("diff lines = %q, want %q", changes[0].Lines, wantLines)
+	}
+}
+
+func TestMemoryRepositoryRevertSong(t *testing.T) {
+	repo := NewSongRepository()
+	ctx := context.Background()
+
+	song, _ := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Uprising", SongText: text("first")})
+	repo.UpdateSong(ctx, &database.Song{ID: song.ID, GroupName: "Muse", Song: "Uprising", SongText: text("second")}, 0)
+	current, _ := repo.GetSongByID(ctx, song.ID)
+
+	if _, err := repo.RevertSong(ctx, song.ID, 1, current.Version-1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("stale version: %v, want ErrVersionMismatch", err)
+	}
+	if _, err := repo.RevertSong(ctx, song.ID, 42, 0); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("unknown revision: %v, want ErrRevisionNotFound", err)
+	}
+
+	reverted, err := repo.RevertSong(ctx, song.ID, 1, current.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.SongText.String != "first" || reverted.Version <= current.Version {
+		t.Fatalf("reverted = %q v%d, want %q with version above %d", reverted.SongText.String, reverted.Version, "first", current.Version)
+	}
+	revisions, _ := repo.ListRevisions(ctx, song.ID)
+	last := revisions[len(revisions)-1]
+	if last.Action != RevisionRevert || last.RevertedFrom != 1 {
+		t.Errorf("last revision = %s from %d, want revert from 1", last.Action, last.RevertedFrom)
+	}
+
+	repo.DeleteSong(ctx, song.ID, 0)
+	deleted := int32(len(revisions) + 1)
+	if _, err := repo.RevertSong(ctx, song.ID, deleted, 0); !errors.Is(err, ErrDeletionRevision) {
+		t.Errorf("revert to a deletion: %v, want ErrDeletionRevision", err)
+	}
+}
+
+func TestMemoryRepositoryRevertRecreatesPurgedSong(t *testing.T) {
+	repo := NewSongRepository()
+	ctx := context.Background()
+
+	song, _ := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Uprising"})
+	repo.DeleteSong(ctx, song.ID, 0)
+	if n, _ := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute)); n != 1 {
+		t.Fatalf("purged %d songs, want 1", n)
+	}
+	if _, err := repo.UndeleteSong(ctx, song.ID); !errors.Is(err, ErrSongNotFound) {
+		t.Fatalf("UndeleteSong after purge: %v, want ErrSongNotFound", err)
+	}
+
+	reverted, err := repo.RevertSong(ctx, song.ID, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.ID != song.ID {
+		t.Fatalf("recreated song has ID %d, want %d", reverted.ID, song.ID)
+	}
+	if _, err := repo.GetSongByName(ctx, "Muse", "Uprising"); err != nil {
+		t.Fatalf("recreated song is not visible: %v", err)
+	}
+}
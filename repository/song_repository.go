@@ -2,63 +2,757 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/tenant"
 )
 
+// SongRepository хранит песни всех клиентов в памяти процесса. ID песен
+// общие, как у последовательности в PostgreSQL.
 type SongRepository struct {
+	mu      sync.RWMutex
 	storage map[int32]*database.Song
-	lastID  int32
+	byName  map[songKey]int32
+	// counts — число песен каждого клиента для проверки квоты
+	counts map[int32]int
+	// trash — песни в корзине по ID
+	trash map[int32]*database.Song
+	// revisions — история песен по ID, в том числе удаленных
+	revisions map[int32][]*Revision
+	lastID    int32
+	// groups — группы по ID, groupIDs — ID группы по клиенту и имени
+	groups      map[int32]*Group
+	groupIDs    map[groupKey]int32
+	lastGroupID int32
+}
+
+// songKey — клиент и нормализованная пара группа + название для проверки уникальности.
+type songKey struct {
+	tenant int32
+	group  string
+	song   string
+}
+
+func keyOf(song *database.Song) songKey {
+	return songKey{tenant: song.TenantID, group: NormalizeName(song.GroupName), song: NormalizeName(song.Song)}
+}
+
+// groupKey — клиент и нормализованное имя группы для проверки уникальности.
+type groupKey struct {
+	tenant int32
+	name   string
+}
+
+func groupKeyOf(tenantID int32, name string) groupKey {
+	return groupKey{tenant: tenantID, name: NormalizeName(name)}
 }
 
 func NewSongRepository() *SongRepository {
 	return &SongRepository{
-		storage: make(map[int32]*database.Song),
-		lastID:  0,
+		storage:   make(map[int32]*database.Song),
+		byName:    make(map[songKey]int32),
+		counts:    make(map[int32]int),
+		trash:     make(map[int32]*database.Song),
+		revisions: make(map[int32][]*Revision),
+		lastID:    0,
+		groups:    make(map[int32]*Group),
+		groupIDs:  make(map[groupKey]int32),
+	}
+}
+
+// get возвращает песню клиента из контекста. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) get(ctx context.Context, id int32) (*database.Song, bool) {
+	song, exists := repo.storage[id]
+	if !exists || song.TenantID != tenant.ID(ctx) {
+		return nil, false
 	}
+	return song, true
 }
 
-// Получить список всех песен
-func (repo *SongRepository) GetAllSongs(ctx context.Context) ([]*database.Song, error) {
-	songs := make([]*database.Song, 0, len(repo.storage))
+// record записывает снимок песни в историю. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) record(ctx context.Context, action string, song *database.Song, revertedFrom int32) {
+	history := repo.revisions[song.ID]
+	repo.revisions[song.ID] = append(history, &Revision{
+		Revision:     int32(len(history)) + 1,
+		SongID:       song.ID,
+		Action:       action,
+		Actor:        actor(ctx),
+		RevertedFrom: revertedFrom,
+		CreatedAt:    time.Now(),
+		Song:         *song,
+	})
+}
+
+// history возвращает историю песни клиента из контекста. Вызывается под
+// блокировкой repo.mu.
+func (repo *SongRepository) history(ctx context.Context, id int32) []*Revision {
+	history := repo.revisions[id]
+	if len(history) == 0 || history[0].Song.TenantID != tenant.ID(ctx) {
+		return nil
+	}
+	return history
+}
+
+// attachGroup находит группу песни по названию или создает ее и заменяет
+// название группы в песне именем группы. Вызывается под блокировкой repo.mu
+// после всех проверок, чтобы отклоненное изменение не оставило новую группу.
+func (repo *SongRepository) attachGroup(song *database.Song) {
+	key := groupKeyOf(song.TenantID, song.GroupName)
+	id, exists := repo.groupIDs[key]
+	if !exists {
+		repo.lastGroupID++
+		id = repo.lastGroupID
+		repo.groups[id] = &Group{
+			ID:        id,
+			Name:      strings.TrimSpace(song.GroupName),
+			Aliases:   []string{},
+			CreatedAt: time.Now(),
+			TenantID:  song.TenantID,
+		}
+		repo.groupIDs[key] = id
+	}
+	song.GroupID = id
+	song.GroupName = repo.groups[id].Name
+}
+
+// resolveGroup заменяет название группы в песне именем группы GroupID, если
+// он задан; расходящееся с ним название — ErrGroupMismatch. Вызывается под
+// блокировкой repo.mu до проверки уникальности, которая идет по названию.
+func (repo *SongRepository) resolveGroup(ctx context.Context, song *database.Song) error {
+	if song.GroupID == 0 {
+		return nil
+	}
+	group, exists := repo.group(ctx, song.GroupID)
+	if !exists {
+		return ErrGroupNotFound
+	}
+	return adoptGroupName(song, group.Name)
+}
+
+// insert добавляет новую песню клиента из контекста, если это позволяет его
+// квота. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) insert(ctx context.Context, song *database.Song, key songKey) error {
+	if t := tenant.FromContext(ctx); t != nil && t.MaxSongs != nil && repo.counts[t.ID] >= int(*t.MaxSongs) {
+		return ErrQuotaExceeded
+	}
+	repo.attachGroup(song)
+	repo.lastID++
+	song.ID = repo.lastID
+	song.Version = 1
+	repo.storage[song.ID] = song
+	repo.byName[key] = song.ID
+	repo.counts[song.TenantID]++
+	return nil
+}
+
+// Получить список песен, подходящих под фильтр
+func (repo *SongRepository) GetAllSongs(ctx context.Context, filter SongFilter) ([]*database.Song, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	tenantID := tenant.ID(ctx)
+	songs := make([]*database.Song, 0, repo.counts[tenantID])
 	for _, song := range repo.storage {
-		songs = append(songs, song)
+		if song.TenantID == tenantID && filter.Match(song) {
+			songs = append(songs, song)
+		}
 	}
+	sort.Slice(songs, func(i, j int) bool { return songs[i].ID < songs[j].ID })
 	return songs, nil
 }
 
+// Передать песни, подходящие под фильтр, по одной. Обход идет по снимку,
+// сделанному под блокировкой, чтобы медленный потребитель не блокировал запись.
+func (repo *SongRepository) StreamSongs(ctx context.Context, filter SongFilter, fn func(*database.Song) error) error {
+	songs, err := repo.GetAllSongs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(song); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Посчитать песни
+func (repo *SongRepository) CountSongs(ctx context.Context) (int64, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return int64(repo.counts[tenant.ID(ctx)]), nil
+}
+
 // Получить песню по ID
 func (repo *SongRepository) GetSongByID(ctx context.Context, id int32) (*database.Song, error) {
-	song, exists := repo.storage[id]
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	song, exists := repo.get(ctx, id)
 	if !exists {
-		return nil, errors.New("song not found")
+		return nil, ErrSongNotFound
 	}
 	return song, nil
 }
 
+// Получить песню по группе и названию
+func (repo *SongRepository) GetSongByName(ctx context.Context, group, song string) (*database.Song, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	id, exists := repo.byName[songKey{tenant: tenant.ID(ctx), group: NormalizeName(group), song: NormalizeName(song)}]
+	if !exists {
+		return nil, ErrSongNotFound
+	}
+	return repo.storage[id], nil
+}
+
 // Добавить новую песню
 func (repo *SongRepository) CreateSong(ctx context.Context, song *database.Song) (*database.Song, error) {
-	repo.lastID++
-	song.ID = repo.lastID
-	repo.storage[song.ID] = song
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	song.TenantID = tenant.ID(ctx)
+	if err := repo.resolveGroup(ctx, song); err != nil {
+		return nil, err
+	}
+	key := keyOf(song)
+	if _, exists := repo.byName[key]; exists {
+		return nil, ErrSongExists
+	}
+	if err := repo.insert(ctx, song, key); err != nil {
+		return nil, err
+	}
+	repo.record(ctx, RevisionCreate, song, 0)
 	return song, nil
 }
 
 // Обновить песню
-func (repo *SongRepository) UpdateSong(ctx context.Context, song *database.Song) error {
-	if _, exists := repo.storage[song.ID]; !exists {
-		return errors.New("song not found")
+func (repo *SongRepository) UpdateSong(ctx context.Context, song *database.Song, expectedVersion int32) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	old, exists := repo.get(ctx, song.ID)
+	if !exists {
+		return ErrSongNotFound
+	}
+	if expectedVersion != 0 && old.Version != expectedVersion {
+		return ErrVersionMismatch
 	}
+	song.TenantID = old.TenantID
+	if err := repo.resolveGroup(ctx, song); err != nil {
+		return err
+	}
+	key := keyOf(song)
+	if id, taken := repo.byName[key]; taken && id != song.ID {
+		return ErrSongExists
+	}
+	song.Version = old.Version + 1
+	repo.attachGroup(song)
+	delete(repo.byName, keyOf(old))
 	repo.storage[song.ID] = song
+	repo.byName[key] = song.ID
+	repo.record(ctx, RevisionUpdate, song, 0)
 	return nil
 }
 
-// Удалить песню
-func (repo *SongRepository) DeleteSong(ctx context.Context, id int32) error {
-	if _, exists := repo.storage[id]; !exists {
-		return errors.New("song not found")
+// Создать или обновить песню по группе и названию
+func (repo *SongRepository) UpsertSong(ctx context.Context, song *database.Song) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	song.TenantID = tenant.ID(ctx)
+	key := keyOf(song)
+	id, exists := repo.byName[key]
+	if !exists {
+		if err := repo.insert(ctx, song, key); err != nil {
+			return false, err
+		}
+		repo.record(ctx, RevisionCreate, song, 0)
+		return true, nil
+	}
+
+	existing := *repo.storage[id]
+	existing.ReleaseDate = song.ReleaseDate
+	existing.ReleaseDatePrecision = song.ReleaseDatePrecision
+	existing.SongText = song.SongText
+	existing.Link = song.Link
+	existing.Version++
+	repo.storage[id] = &existing
+	*song = existing
+	repo.record(ctx, RevisionUpdate, song, 0)
+	return false, nil
+}
+
+// Выполнить пакет операций: операции применяются к копии хранилища,
+// которая заменяет исходное только при успехе.
+func (repo *SongRepository) ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	draft := repo.clone()
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = applyOperation(ctx, draft, op)
+		if results[i].Err != nil && atomic {
+			abortBatch(results, i)
+			return results, nil
+		}
+	}
+
+	repo.adopt(draft)
+	return results, nil
+}
+
+// adopt заменяет состояние хранилища состоянием draft. Вызывается под
+// блокировкой repo.mu.
+func (repo *SongRepository) adopt(draft *SongRepository) {
+	repo.storage, repo.byName, repo.counts = draft.storage, draft.byName, draft.counts
+	repo.trash, repo.revisions, repo.lastID = draft.trash, draft.revisions, draft.lastID
+	repo.groups, repo.groupIDs, repo.lastGroupID = draft.groups, draft.groupIDs, draft.lastGroupID
+}
+
+// clone копирует состояние хранилища. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) clone() *SongRepository {
+	draft := &SongRepository{
+		storage:     make(map[int32]*database.Song, len(repo.storage)),
+		byName:      make(map[songKey]int32, len(repo.byName)),
+		counts:      make(map[int32]int, len(repo.counts)),
+		trash:       make(map[int32]*database.Song, len(repo.trash)),
+		revisions:   make(map[int32][]*Revision, len(repo.revisions)),
+		lastID:      repo.lastID,
+		groups:      make(map[int32]*Group, len(repo.groups)),
+		groupIDs:    make(map[groupKey]int32, len(repo.groupIDs)),
+		lastGroupID: repo.lastGroupID,
+	}
+	for id, song := range repo.storage {
+		draft.storage[id] = song
+	}
+	for key, id := range repo.byName {
+		draft.byName[key] = id
+	}
+	for tenantID, n := range repo.counts {
+		draft.counts[tenantID] = n
+	}
+	for id, song := range repo.trash {
+		draft.trash[id] = song
 	}
+	for id, history := range repo.revisions {
+		// Без запаса емкости append в копии не изменит исходный массив
+		draft.revisions[id] = slices.Clip(history)
+	}
+	for id, group := range repo.groups {
+		// Группы не изменяются на месте, поэтому копия делит их с исходным хранилищем
+		draft.groups[id] = group
+	}
+	for key, id := range repo.groupIDs {
+		draft.groupIDs[key] = id
+	}
+	return draft
+}
+
+// Переместить песню в корзину
+func (repo *SongRepository) DeleteSong(ctx context.Context, id int32, expectedVersion int32) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	song, exists := repo.get(ctx, id)
+	if !exists {
+		return ErrSongNotFound
+	}
+	if expectedVersion != 0 && song.Version != expectedVersion {
+		return ErrVersionMismatch
+	}
+	delete(repo.byName, keyOf(song))
 	delete(repo.storage, id)
+	repo.counts[song.TenantID]--
+	trashed := *song
+	trashed.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	repo.trash[id] = &trashed
+	repo.record(ctx, RevisionDelete, &trashed, 0)
+	return nil
+}
+
+// trashed возвращает песню клиента из контекста, если она в корзине.
+// Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) trashed(ctx context.Context, id int32) (*database.Song, bool) {
+	song, exists := repo.trash[id]
+	if !exists || song.TenantID != tenant.ID(ctx) {
+		return nil, false
+	}
+	return song, true
+}
+
+// Получить песни из корзины
+func (repo *SongRepository) ListDeleted(ctx context.Context) ([]*database.Song, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	tenantID := tenant.ID(ctx)
+	songs := []*database.Song{}
+	for _, song := range repo.trash {
+		if song.TenantID == tenantID {
+			songs = append(songs, song)
+		}
+	}
+	sort.Slice(songs, func(i, j int) bool {
+		if !songs[i].DeletedAt.Time.Equal(songs[j].DeletedAt.Time) {
+			return songs[i].DeletedAt.Time.After(songs[j].DeletedAt.Time)
+		}
+		return songs[i].ID < songs[j].ID
+	})
+	return songs, nil
+}
+
+// Вернуть песню из корзины
+func (repo *SongRepository) UndeleteSong(ctx context.Context, id int32) (*database.Song, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	trashed, exists := repo.trashed(ctx, id)
+	if !exists {
+		return nil, ErrSongNotFound
+	}
+	song := *trashed
+	song.DeletedAt = sql.NullTime{}
+	song.Version++
+	if err := repo.revive(ctx, &song); err != nil {
+		return nil, err
+	}
+	repo.record(ctx, RevisionRestore, &song, 0)
+	return &song, nil
+}
+
+// revive возвращает удаленную песню в хранилище с прежним ID, проверив
+// уникальность и квоту. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) revive(ctx context.Context, song *database.Song) error {
+	key := keyOf(song)
+	if _, taken := repo.byName[key]; taken {
+		return ErrSongExists
+	}
+	if t := tenant.FromContext(ctx); t != nil && t.MaxSongs != nil && repo.counts[t.ID] >= int(*t.MaxSongs) {
+		return ErrQuotaExceeded
+	}
+	repo.attachGroup(song)
+	delete(repo.trash, song.ID)
+	repo.storage[song.ID] = song
+	repo.byName[key] = song.ID
+	repo.counts[song.TenantID]++
+	return nil
+}
+
+// Окончательно удалить песни, давно попавшие в корзину
+func (repo *SongRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	var purged int64
+	for id, song := range repo.trash {
+		if song.DeletedAt.Time.Before(before) {
+			delete(repo.trash, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Получить историю песни
+func (repo *SongRepository) ListRevisions(ctx context.Context, id int32) ([]*Revision, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	history := repo.history(ctx, id)
+	if history == nil {
+		return nil, ErrSongNotFound
+	}
+	return slices.Clone(history), nil
+}
+
+// Откатить песню к ревизии
+func (repo *SongRepository) RevertSong(ctx context.Context, id, rev int32, expectedVersion int32) (*database.Song, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	history := repo.history(ctx, id)
+	target, err := revertTarget(history, rev)
+	if err != nil {
+		return nil, err
+	}
+	song := target.Song
+	song.DeletedAt = sql.NullTime{}
+
+	current, exists := repo.get(ctx, id)
+	if !exists {
+		// Песня в корзине или удалена окончательно: возвращаем ее с тем же ID
+		if expectedVersion != 0 {
+			return nil, ErrVersionMismatch
+		}
+		song.Version = history[len(history)-1].Song.Version + 1
+		if err := repo.revive(ctx, &song); err != nil {
+			return nil, err
+		}
+		repo.record(ctx, RevisionRevert, &song, rev)
+		return &song, nil
+	}
+
+	if expectedVersion != 0 && current.Version != expectedVersion {
+		return nil, ErrVersionMismatch
+	}
+	key := keyOf(&song)
+	if taken, exists := repo.byName[key]; exists && taken != id {
+		return nil, ErrSongExists
+	}
+	song.Version = current.Version + 1
+	repo.attachGroup(&song)
+	delete(repo.byName, keyOf(current))
+	repo.storage[id] = &song
+	repo.byName[key] = id
+	repo.record(ctx, RevisionRevert, &song, rev)
+	return &song, nil
+}
+
+// Заменить все песни клиента, сохранив их ID и версии. Песни других
+// клиентов остаются как есть.
+func (repo *SongRepository) RestoreSongs(ctx context.Context, groups []*Group, songs []*database.Song) error {
+	tenantID := tenant.ID(ctx)
+	if t := tenant.FromContext(ctx); t != nil && t.MaxSongs != nil && len(songs) > int(*t.MaxSongs) {
+		return ErrQuotaExceeded
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	draft := repo.clone()
+	var replaced []*database.Song
+	for id, song := range draft.storage {
+		if song.TenantID == tenantID {
+			replaced = append(replaced, song)
+			delete(draft.byName, keyOf(song))
+			delete(draft.storage, id)
+		}
+	}
+	// Корзина клиента очищается, как и в PostgreSQL
+	for id, song := range draft.trash {
+		if song.TenantID == tenantID {
+			delete(draft.trash, id)
+		}
+	}
+	draft.counts[tenantID] = 0
+	if groups != nil {
+		if err := draft.restoreGroups(tenantID, groups); err != nil {
+			return err
+		}
+	}
+	for _, song := range songs {
+		if _, exists := draft.storage[song.ID]; exists {
+			return fmt.Errorf("duplicate song ID %d", song.ID)
+		}
+		if _, exists := draft.trash[song.ID]; exists {
+			return fmt.Errorf("song ID %d belongs to another tenant", song.ID)
+		}
+		if history := draft.revisions[song.ID]; len(history) > 0 && history[0].Song.TenantID != tenantID {
+			return fmt.Errorf("song ID %d belongs to another tenant", song.ID)
+		}
+		restored := *song
+		restored.TenantID = tenantID
+		key := keyOf(&restored)
+		if _, exists := draft.byName[key]; exists {
+			return fmt.Errorf("%w: %s - %s", ErrSongExists, song.GroupName, song.Song)
+		}
+		draft.attachGroup(&restored)
+		draft.storage[song.ID] = &restored
+		draft.byName[key] = song.ID
+		draft.counts[tenantID]++
+		draft.record(ctx, RevisionRestore, &restored, 0)
+	}
+	// История песен, которых нет в архиве, заканчивается удалением; у песен
+	// из корзины оно уже записано
+	for _, song := range replaced {
+		if _, kept := draft.storage[song.ID]; !kept {
+			draft.record(ctx, RevisionDelete, song, 0)
+		}
+	}
+
+	// Как и в PostgreSQL, новые ID продолжаются после наибольшего существующего;
+	// ID удаленных песен с историей тоже заняты
+	draft.lastID = 0
+	for id := range draft.storage {
+		draft.lastID = max(draft.lastID, id)
+	}
+	for id := range draft.revisions {
+		draft.lastID = max(draft.lastID, id)
+	}
+	draft.lastGroupID = 0
+	for id := range draft.groups {
+		draft.lastGroupID = max(draft.lastGroupID, id)
+	}
+	repo.adopt(draft)
+	return nil
+}
+
+// restoreGroups заменяет группы клиента переданными с прежними ID. Песни
+// клиента к этому моменту уже удалены.
+func (repo *SongRepository) restoreGroups(tenantID int32, groups []*Group) error {
+	for id, group := range repo.groups {
+		if group.TenantID == tenantID {
+			delete(repo.groupIDs, groupKeyOf(tenantID, group.Name))
+			delete(repo.groups, id)
+		}
+	}
+	for _, group := range groups {
+		if _, exists := repo.groups[group.ID]; exists {
+			return fmt.Errorf("group ID %d belongs to another tenant", group.ID)
+		}
+		key := groupKeyOf(tenantID, group.Name)
+		if _, exists := repo.groupIDs[key]; exists {
+			return fmt.Errorf("%w: %s", ErrGroupExists, group.Name)
+		}
+		restored := *group
+		restored.TenantID = tenantID
+		if restored.Aliases == nil {
+			restored.Aliases = []string{}
+		}
+		repo.groups[group.ID] = &restored
+		repo.groupIDs[key] = group.ID
+	}
+	return nil
+}
+
+// Получить группы
+func (repo *SongRepository) ListGroups(ctx context.Context, query string) ([]*Group, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	tenantID := tenant.ID(ctx)
+	groups := []*Group{}
+	for _, group := range repo.groups {
+		if group.TenantID == tenantID && group.Match(query) {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := NormalizeName(groups[i].Name), NormalizeName(groups[j].Name)
+		if a != b {
+			return a < b
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+// group возвращает группу клиента из контекста. Вызывается под блокировкой repo.mu.
+func (repo *SongRepository) group(ctx context.Context, id int32) (*Group, bool) {
+	group, exists := repo.groups[id]
+	if !exists || group.TenantID != tenant.ID(ctx) {
+		return nil, false
+	}
+	return group, true
+}
+
+// Получить группу по ID
+func (repo *SongRepository) GetGroup(ctx context.Context, id int32) (*Group, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	group, exists := repo.group(ctx, id)
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// Добавить группу
+func (repo *SongRepository) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	created := *group
+	created.TenantID = tenant.ID(ctx)
+	key := groupKeyOf(created.TenantID, created.Name)
+	if _, exists := repo.groupIDs[key]; exists {
+		return nil, ErrGroupExists
+	}
+	repo.lastGroupID++
+	created.ID = repo.lastGroupID
+	created.CreatedAt = time.Now()
+	repo.groups[created.ID] = &created
+	repo.groupIDs[key] = created.ID
+	return &created, nil
+}
+
+// Обновить группу и переименовать ее песни
+func (repo *SongRepository) UpdateGroup(ctx context.Context, group *Group) (*Group, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	old, exists := repo.group(ctx, group.ID)
+	if !exists {
+		return nil, ErrGroupNotFound
+	}
+	key := groupKeyOf(old.TenantID, group.Name)
+	if id, taken := repo.groupIDs[key]; taken && id != old.ID {
+		return nil, ErrGroupExists
+	}
+	updated := *group
+	updated.TenantID, updated.CreatedAt = old.TenantID, old.CreatedAt
+	delete(repo.groupIDs, groupKeyOf(old.TenantID, old.Name))
+	repo.groups[updated.ID] = &updated
+	repo.groupIDs[key] = updated.ID
+	if updated.Name == old.Name {
+		return &updated, nil
+	}
+
+	// Песни копируются: прежние значения могли уже получить читатели
+	for id, song := range repo.storage {
+		if song.GroupID != updated.ID {
+			continue
+		}
+		renamed := *song
+		renamed.GroupName = updated.Name
+		renamed.Version++
+		delete(repo.byName, keyOf(song))
+		repo.storage[id] = &renamed
+		repo.byName[keyOf(&renamed)] = id
+		repo.record(ctx, RevisionUpdate, &renamed, 0)
+	}
+	for id, song := range repo.trash {
+		if song.GroupID == updated.ID {
+			renamed := *song
+			renamed.GroupName = updated.Name
+			repo.trash[id] = &renamed
+		}
+	}
+	return &updated, nil
+}
+
+// Удалить группу без песен
+func (repo *SongRepository) DeleteGroup(ctx context.Context, id int32) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	group, exists := repo.group(ctx, id)
+	if !exists {
+		return ErrGroupNotFound
+	}
+	for _, songs := range []map[int32]*database.Song{repo.storage, repo.trash} {
+		for _, song := range songs {
+			if song.GroupID == id {
+				return ErrGroupNotEmpty
+			}
+		}
+	}
+	delete(repo.groupIDs, groupKeyOf(group.TenantID, group.Name))
+	delete(repo.groups, id)
 	return nil
 }
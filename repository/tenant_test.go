@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/Kitrop/songGO-lib/tenant"
+)
+
+func tenantContext(id int32, maxSongs *int32) context.Context {
+	return tenant.WithTenant(context.Background(), &tenant.Tenant{ID: id, Slug: "t", MaxSongs: maxSongs})
+}
+
+func TestMemoryRepositoryIsolatesTenants(t *testing.T) {
+	repo := NewSongRepository()
+	acme, other := tenantContext(2, nil), tenantContext(3, nil)
+
+	song, err := repo.CreateSong(acme, &database.Song{GroupName: "Muse", Song: "Uprising"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateSong(other, &database.Song{GroupName: "Muse", Song: "Uprising"}); err != nil {
+		t.Fatalf("same song in another tenant: %v", err)
+	}
+	if _, err := repo.CreateSong(acme, &database.Song{GroupName: "muse", Song: " Uprising"}); !errors.Is(err, ErrSongExists) {
+		t.Fatalf("duplicate in the same tenant: %v, want ErrSongExists", err)
+	}
+
+	if _, err := repo.GetSongByID(other, song.ID); !errors.Is(err, ErrSongNotFound) {
+		t.Errorf("GetSongByID from another tenant: %v, want ErrSongNotFound", err)
+	}
+	if err := repo.UpdateSong(other, &database.Song{ID: song.ID, GroupName: "Muse", Song: "Hijacked"}, 0); !errors.Is(err, ErrSongNotFound) {
+		t.Errorf("UpdateSong from another tenant: %v, want ErrSongNotFound", err)
+	}
+	if err := repo.DeleteSong(other, song.ID, 0); !errors.Is(err, ErrSongNotFound) {
+		t.Errorf("DeleteSong from another tenant: %v, want ErrSongNotFound", err)
+	}
+	if _, err := repo.ListRevisions(other, song.ID); !errors.Is(err, ErrSongNotFound) {
+		t.Errorf("ListRevisions from another tenant: %v, want ErrSongNotFound", err)
+	}
+
+	for ctx, want := range map[context.Context]int64{acme: 1, other: 1, context.Background(): 0} {
+		songs, _ := repo.GetAllSongs(ctx, SongFilter{})
+		count, _ := repo.CountSongs(ctx)
+		if int64(len(songs)) != want || count != want {
+			t.Errorf("tenant %d: %d songs, count %d, want %d", tenant.ID(ctx), len(songs), count, want)
+		}
+	}
+}
+
+func TestMemoryRepositoryGroupsArePerTenant(t *testing.T) {
+	repo := NewSongRepository()
+	acme, other := tenantContext(2, nil), tenantContext(3, nil)
+
+	song, _ := repo.CreateSong(acme, &database.Song{GroupName: "Muse", Song: "Uprising"})
+	if _, err := repo.GetGroup(other, song.GroupID); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("GetGroup from another tenant: %v, want ErrGroupNotFound", err)
+	}
+	if _, err := repo.CreateSong(other, &database.Song{GroupID: song.GroupID, Song: "Starlight"}); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("CreateSong into another tenant's group: %v, want ErrGroupNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryEnforcesQuota(t *testing.T) {
+	repo := NewSongRepository()
+	limit := int32(1)
+	ctx := tenantContext(2, &limit)
+
+	first, err := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Uprising"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Starlight"}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("CreateSong over quota: %v, want ErrQuotaExceeded", err)
+	}
+
+	if err := repo.DeleteSong(ctx, first.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateSong(ctx, &database.Song{GroupName: "Muse", Song: "Starlight"}); err != nil {
+		t.Fatalf("CreateSong after freeing the quota: %v", err)
+	}
+	if _, err := repo.UndeleteSong(ctx, first.ID); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("UndeleteSong over quota: %v, want ErrQuotaExceeded", err)
+	}
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// RunPurge с периодом interval окончательно удаляет песни, пролежавшие в
+// корзине дольше retention, пока не отменен ctx. История удаленных песен
+// сохраняется.
+func RunPurge(ctx context.Context, repo Repository, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to purge the trash", "error", err)
+				continue
+			}
+			if purged > 0 {
+				logger.InfoContext(ctx, "purged songs from the trash", "count", purged, "retention", retention)
+			}
+		}
+	}
+}
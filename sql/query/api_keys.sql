@@ -0,0 +1,34 @@
+-- Если клиента с указанным slug нет, ключ не создается и запрос не возвращает строк.
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (name, prefix, key_hash, role, tenant_id)
+SELECT @name, @prefix, @key_hash, @role, tenants.id
+FROM (SELECT sqlc.narg('tenant')::text AS slug) AS requested
+LEFT JOIN tenants ON tenants.slug = requested.slug
+WHERE requested.slug IS NULL OR tenants.id IS NOT NULL
+RETURNING *;
+
+-- name: GetActiveAPIKeyByHash :one
+SELECT sqlc.embed(api_keys), tenants.slug AS tenant
+FROM api_keys LEFT JOIN tenants ON tenants.id = api_keys.tenant_id
+WHERE api_keys.key_hash = $1 AND api_keys.revoked_at IS NULL;
+
+-- name: ListAPIKeys :many
+SELECT sqlc.embed(api_keys), tenants.slug AS tenant
+FROM api_keys LEFT JOIN tenants ON tenants.id = api_keys.tenant_id
+ORDER BY api_keys.id;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL;
+
+-- Время последнего использования обновляется не чаще раза в минуту,
+-- чтобы не писать в базу на каждый запрос.
+-- name: TouchAPIKey :exec
+UPDATE api_keys SET last_used_at = now()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute');
+
+-- name: InsertAccessDenial :exec
+INSERT INTO access_denials (subject, key_id, role, required_role, method, path, remote_addr, request_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: ListAccessDenials :many
+SELECT * FROM access_denials ORDER BY id DESC LIMIT $1;
@@ -0,0 +1,46 @@
+-- name: ListGroups :many
+-- Поиск по подстроке имени или псевдонима без учета регистра и пробелов
+SELECT * FROM groups
+WHERE tenant_id = @tenant_id
+  AND (@query::text = ''
+    OR strpos(normalize_name(name), normalize_name(@query::text)) > 0
+    OR EXISTS (SELECT 1 FROM unnest(aliases) AS alias WHERE strpos(normalize_name(alias), normalize_name(@query::text)) > 0))
+ORDER BY normalize_name(name), id;
+
+-- name: GetGroup :one
+SELECT * FROM groups WHERE id = $1 AND tenant_id = $2;
+
+-- name: LockGroup :one
+-- Блокирует группу до конца транзакции, чтобы ее не переименовали и не
+-- удалили, пока к ней добавляется песня
+SELECT * FROM groups WHERE id = $1 AND tenant_id = $2 FOR SHARE;
+
+-- name: CreateGroup :one
+INSERT INTO groups (tenant_id, name, country, formed_year, aliases)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: UpdateGroup :one
+UPDATE groups SET name = $3, country = $4, formed_year = $5, aliases = $6
+WHERE id = $1 AND tenant_id = $2
+RETURNING *;
+
+-- name: RenameGroupSongs :many
+-- Версия меняется только у песен вне корзины: у них записывается ревизия
+UPDATE songs SET group_name = @name,
+    version = CASE WHEN deleted_at IS NULL THEN version + 1 ELSE version END
+WHERE group_id = @group_id
+RETURNING *;
+
+-- name: DeleteGroup :execrows
+DELETE FROM groups WHERE id = $1 AND tenant_id = $2;
+
+-- name: DeleteAllGroups :exec
+DELETE FROM groups WHERE tenant_id = $1;
+
+-- name: RestoreGroup :exec
+INSERT INTO groups (id, tenant_id, name, country, formed_year, aliases, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ResetGroupsIDSequence :exec
+SELECT setval(pg_get_serial_sequence('groups', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM groups;
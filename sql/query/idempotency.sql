@@ -0,0 +1,28 @@
+-- name: ReserveIdempotencyKey :execrows
+INSERT INTO idempotency_keys (key, request_hash, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE
+SET request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    response_headers = '{}',
+    response_body = NULL,
+    created_at = now(),
+    expires_at = EXCLUDED.expires_at
+WHERE idempotency_keys.expires_at < now();
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys WHERE key = $1;
+
+-- name: ExtendIdempotencyKey :exec
+UPDATE idempotency_keys SET expires_at = $2 WHERE key = $1 AND status_code IS NULL;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status_code = $2, response_headers = $3, response_body = $4, expires_at = $5
+WHERE key = $1;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys WHERE expires_at < now();
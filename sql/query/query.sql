@@ -1,23 +1,84 @@
 -- name: GetSongs :many
 SELECT * FROM songs
-WHERE ($1::text IS NULL OR group_name = $1)
-  AND ($2::text IS NULL OR song = $2)
-  AND ($3::text IS NULL OR release_date = $3)
+WHERE tenant_id = @tenant_id AND deleted_at IS NULL
+  AND (sqlc.narg('group_name')::text IS NULL OR group_name = sqlc.narg('group_name'))
+  AND (sqlc.narg('group_id')::int IS NULL OR group_id = sqlc.narg('group_id'))
+  AND (sqlc.narg('song')::text IS NULL OR song = sqlc.narg('song'))
+  AND (sqlc.narg('released_after')::date IS NULL OR release_date >= sqlc.narg('released_after'))
+  AND (sqlc.narg('released_before')::date IS NULL OR release_date <= sqlc.narg('released_before'))
+  AND (sqlc.narg('year')::int IS NULL OR date_part('year', release_date) = sqlc.narg('year'))
 ORDER BY id
-LIMIT $4
-OFFSET $5;
+LIMIT sqlc.narg('limit')
+OFFSET sqlc.narg('offset');
 
 -- name: GetSongByID :one
-SELECT * FROM songs WHERE id = $1;
+SELECT * FROM songs WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL;
 
 -- name: CreateSong :one
-INSERT INTO songs (group_name, song, release_date, song_text, link)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO songs (group_name, song, release_date, release_date_precision, song_text, link, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
--- name: UpdateSong :exec
-UPDATE songs SET group_name = $2, song = $3, release_date = $4, song_text = $5, link = $6
-WHERE id = $1;
+-- name: UpdateSong :one
+UPDATE songs SET group_name = $2, song = $3, release_date = $4, release_date_precision = $5, song_text = $6, link = $7,
+    version = version + 1
+WHERE id = $1 AND tenant_id = $8 AND deleted_at IS NULL
+  AND (sqlc.narg('expected_version')::int IS NULL OR version = sqlc.narg('expected_version'))
+RETURNING *;
+
+-- name: DeleteSong :one
+UPDATE songs SET deleted_at = now()
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+  AND (sqlc.narg('expected_version')::int IS NULL OR version = sqlc.narg('expected_version'))
+RETURNING *;
+
+-- name: GetSongByName :one
+SELECT * FROM songs
+WHERE tenant_id = @tenant_id AND deleted_at IS NULL
+  AND normalize_name(group_name) = normalize_name(@group_name::text)
+  AND normalize_name(song) = normalize_name(@song::text);
+
+-- name: UpsertSong :one
+INSERT INTO songs (group_name, song, release_date, release_date_precision, song_text, link, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (tenant_id, normalize_name(group_name), normalize_name(song)) WHERE deleted_at IS NULL DO UPDATE
+SET release_date = EXCLUDED.release_date,
+    release_date_precision = EXCLUDED.release_date_precision,
+    song_text = EXCLUDED.song_text,
+    link = EXCLUDED.link,
+    version = songs.version + 1
+RETURNING id, group_name, song, release_date, song_text, link, release_date_precision, version, tenant_id, group_id, (xmax = 0)::boolean AS inserted;
+
+-- name: DeleteAllSongs :many
+DELETE FROM songs WHERE tenant_id = $1
+RETURNING *;
+
+-- name: RestoreSong :one
+INSERT INTO songs (id, group_name, song, release_date, release_date_precision, song_text, link, version, tenant_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: ResetSongsIDSequence :exec
+-- ID удаленных песен с историей тоже заняты, чтобы история не досталась новой песне
+SELECT setval(pg_get_serial_sequence('songs', 'id'), COALESCE(last_id, 1), last_id IS NOT NULL)
+FROM (SELECT GREATEST((SELECT MAX(id) FROM songs), (SELECT MAX(song_id) FROM song_revisions)) AS last_id) AS ids;
+
+-- name: CountSongs :one
+SELECT count(*) FROM songs WHERE tenant_id = $1 AND deleted_at IS NULL;
+
+-- name: ListTrash :many
+SELECT * FROM songs
+WHERE tenant_id = $1 AND deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id;
+
+-- name: GetTrashedSong :one
+SELECT * FROM songs WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL;
+
+-- name: UndeleteSong :one
+UPDATE songs SET group_name = $3, song = $4, release_date = $5, release_date_precision = $6, song_text = $7, link = $8,
+    version = version + 1, deleted_at = NULL
+WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NOT NULL
+RETURNING *;
 
--- name: DeleteSong :exec
-DELETE FROM songs WHERE id = $1;
+-- name: PurgeDeletedSongs :execrows
+DELETE FROM songs WHERE deleted_at < $1;
@@ -0,0 +1,24 @@
+-- name: TakeRateLimitToken :one
+-- Пополняет корзину за время с прошлого запроса и забирает токен, если он есть.
+-- Все вычисления в одном запросе, поэтому экземпляры не мешают друг другу.
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at, expires_at)
+VALUES (
+    sqlc.arg(key),
+    sqlc.arg(burst)::float8 - 1,
+    true,
+    now(),
+    now() + make_interval(secs => sqlc.arg(burst)::float8 / sqlc.arg(rate)::float8)
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN rate_limit_refill(b.tokens, b.updated_at, sqlc.arg(burst)::float8, sqlc.arg(rate)::float8) >= 1
+        THEN rate_limit_refill(b.tokens, b.updated_at, sqlc.arg(burst)::float8, sqlc.arg(rate)::float8) - 1
+        ELSE rate_limit_refill(b.tokens, b.updated_at, sqlc.arg(burst)::float8, sqlc.arg(rate)::float8)
+    END,
+    allowed = rate_limit_refill(b.tokens, b.updated_at, sqlc.arg(burst)::float8, sqlc.arg(rate)::float8) >= 1,
+    updated_at = now(),
+    expires_at = EXCLUDED.expires_at
+RETURNING tokens, allowed;
+
+-- name: DeleteExpiredRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE expires_at < now();
@@ -0,0 +1,14 @@
+-- name: CreateSongRevision :exec
+INSERT INTO song_revisions (tenant_id, song_id, revision, action, actor, reverted_from,
+    group_name, song, release_date, release_date_precision, song_text, link, version, group_id)
+SELECT @tenant_id::int, @song_id::int, COALESCE(MAX(revision), 0) + 1, @action::text,
+    sqlc.narg('actor')::text, sqlc.narg('reverted_from')::int,
+    @group_name::text, @song::text, sqlc.narg('release_date')::date, @release_date_precision::date_precision,
+    sqlc.narg('song_text')::text, sqlc.narg('link')::text, @version::int, sqlc.narg('group_id')::int
+FROM song_revisions
+WHERE song_id = @song_id::int;
+
+-- name: ListSongRevisions :many
+SELECT * FROM song_revisions
+WHERE song_id = $1 AND tenant_id = $2
+ORDER BY revision;
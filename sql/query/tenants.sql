@@ -0,0 +1,10 @@
+-- name: CreateTenant :one
+INSERT INTO tenants (slug, name, max_songs)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetTenantBySlug :one
+SELECT * FROM tenants WHERE slug = $1;
+
+-- name: ListTenants :many
+SELECT * FROM tenants ORDER BY id;
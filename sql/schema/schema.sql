@@ -1,8 +1,162 @@
+CREATE TYPE date_precision AS ENUM ('day', 'month', 'year');
+
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9][a-z0-9-]{0,62}$'),
+    name TEXT NOT NULL,
+    max_songs INTEGER CHECK (max_songs >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE groups (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+    name TEXT NOT NULL,
+    country TEXT CHECK (country ~ '^[A-Z]{2}$'),
+    formed_year INTEGER CHECK (formed_year BETWEEN 1000 AND 9999),
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
 CREATE TABLE songs (
     id SERIAL PRIMARY KEY,
     group_name TEXT NOT NULL,
     song TEXT NOT NULL,
-    release_date TEXT,
+    release_date DATE,
+    song_text TEXT,
+    link TEXT,
+    release_date_precision date_precision NOT NULL DEFAULT 'day',
+    version INTEGER NOT NULL DEFAULT 1,
+    tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+    deleted_at TIMESTAMPTZ,
+    group_id INTEGER NOT NULL REFERENCES groups (id)
+);
+
+CREATE INDEX songs_release_date_idx ON songs (release_date);
+
+CREATE INDEX songs_deleted_at_idx ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE FUNCTION normalize_name(name TEXT) RETURNS TEXT AS $$
+    SELECT lower(btrim(regexp_replace(name, '\s+', ' ', 'g')));
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+CREATE UNIQUE INDEX songs_group_song_key ON songs (tenant_id, normalize_name(group_name), normalize_name(song))
+    WHERE deleted_at IS NULL;
+
+CREATE INDEX songs_group_id_idx ON songs (group_id);
+
+CREATE UNIQUE INDEX groups_name_key ON groups (tenant_id, normalize_name(name));
+
+CREATE FUNCTION resolve_song_group() RETURNS trigger AS $$
+DECLARE
+    found groups%ROWTYPE;
+BEGIN
+    SELECT * INTO found FROM groups
+    WHERE tenant_id = NEW.tenant_id AND normalize_name(name) = normalize_name(NEW.group_name);
+    IF NOT FOUND THEN
+        INSERT INTO groups (tenant_id, name) VALUES (NEW.tenant_id, btrim(NEW.group_name))
+        ON CONFLICT DO NOTHING
+        RETURNING * INTO found;
+        IF NOT FOUND THEN
+            SELECT * INTO found FROM groups
+            WHERE tenant_id = NEW.tenant_id AND normalize_name(name) = normalize_name(NEW.group_name);
+        END IF;
+    END IF;
+    NEW.group_id := found.id;
+    NEW.group_name := found.name;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER songs_resolve_group BEFORE INSERT OR UPDATE OF group_name ON songs
+    FOR EACH ROW EXECUTE FUNCTION resolve_song_group();
+
+CREATE FUNCTION check_tenant_quota() RETURNS trigger AS $$
+DECLARE
+    quota INTEGER;
+BEGIN
+    SELECT max_songs INTO quota FROM tenants WHERE id = NEW.tenant_id FOR UPDATE;
+    IF quota IS NOT NULL AND (SELECT count(*) FROM songs WHERE tenant_id = NEW.tenant_id AND deleted_at IS NULL) > quota THEN
+        RAISE EXCEPTION 'tenant % song quota of % exceeded', NEW.tenant_id, quota
+            USING ERRCODE = 'SG001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER songs_tenant_quota AFTER INSERT OR UPDATE OF deleted_at ON songs
+    FOR EACH ROW WHEN (NEW.deleted_at IS NULL) EXECUTE FUNCTION check_tenant_quota();
+
+CREATE TABLE song_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants (id),
+    song_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'revert')),
+    actor TEXT,
+    reverted_from INTEGER,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    group_name TEXT NOT NULL,
+    song TEXT NOT NULL,
+    release_date DATE,
+    release_date_precision date_precision NOT NULL,
     song_text TEXT,
-    link TEXT
-);
\ No newline at end of file
+    link TEXT,
+    version INTEGER NOT NULL,
+    group_id INTEGER,
+    UNIQUE (song_id, revision)
+);
+
+CREATE TABLE idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    response_headers JSONB NOT NULL DEFAULT '{}',
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+CREATE TABLE api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    role TEXT NOT NULL DEFAULT 'reader' CHECK (role IN ('reader', 'editor', 'admin')),
+    tenant_id INTEGER REFERENCES tenants (id)
+);
+
+CREATE TABLE access_denials (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    subject TEXT NOT NULL,
+    key_id INTEGER,
+    role TEXT NOT NULL,
+    required_role TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    remote_addr TEXT NOT NULL,
+    request_id TEXT NOT NULL
+);
+
+CREATE INDEX access_denials_created_at_idx ON access_denials (created_at);
+
+CREATE TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_expires_at_idx ON rate_limit_buckets (expires_at);
+
+CREATE FUNCTION rate_limit_refill(tokens DOUBLE PRECISION, updated_at TIMESTAMPTZ, burst DOUBLE PRECISION, rate DOUBLE PRECISION)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT LEAST(burst, tokens + EXTRACT(EPOCH FROM now() - updated_at)::DOUBLE PRECISION * rate);
+$$ LANGUAGE sql STABLE STRICT;
@@ -0,0 +1,47 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Kitrop/songGO-lib/auth"
+	"github.com/Kitrop/songGO-lib/logging"
+)
+
+var logger = logging.For(logging.ComponentTenant)
+
+// Middleware определяет клиента запроса и кладет его в контекст. Учетные
+// данные, привязанные к клиенту, всегда работают с ним, и заголовок X-Tenant
+// может только совпадать с ним. Ключи и токены без клиента, как и запросы
+// при отключенной аутентификации, выбирают клиента заголовком; без заголовка
+// используется default. Подключается после auth.Middleware.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug := r.Header.Get(HeaderName)
+			if id := auth.FromContext(r.Context()); id != nil && id.Tenant != "" {
+				if slug != "" && slug != id.Tenant {
+					http.Error(w, fmt.Sprintf("credentials are bound to tenant %q", id.Tenant), http.StatusForbidden)
+					return
+				}
+				slug = id.Tenant
+			}
+			if slug == "" {
+				slug = DefaultSlug
+			}
+
+			t, err := store.GetBySlug(r.Context(), slug)
+			if errors.Is(err, ErrNotFound) {
+				http.Error(w, fmt.Sprintf("unknown tenant %q", slug), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				logger.ErrorContext(r.Context(), "failed to look up tenant", "tenant", slug, "error", err)
+				http.Error(w, "failed to resolve the tenant", http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), t)))
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Kitrop/songGO-lib/auth"
+)
+
+// resolve пропускает запрос через Middleware и возвращает статус и клиента,
+// которого получил обработчик.
+func resolve(t *testing.T, store Store, header string, id *auth.Identity) (int, *Tenant) {
+	t.Helper()
+	var got *Tenant
+	h := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/songs", nil)
+	if header != "" {
+		r.Header.Set(HeaderName, header)
+	}
+	if id != nil {
+		r = r.WithContext(auth.WithIdentity(r.Context(), id))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, got
+}
+
+func TestMiddlewareResolvesTenant(t *testing.T) {
+	store := NewMemoryStore()
+	acme, _ := store.Create(context.Background(), Tenant{Slug: "acme", Name: "Acme"})
+	store.Create(context.Background(), Tenant{Slug: "other", Name: "Other"})
+
+	bound := &auth.Identity{Subject: "acme-key", Method: auth.MethodAPIKey, KeyID: 1, Tenant: "acme"}
+	unbound := &auth.Identity{Subject: "ops", Method: auth.MethodAPIKey, KeyID: 2}
+
+	tests := []struct {
+		name   string
+		header string
+		id     *auth.Identity
+		status int
+		tenant int32
+	}{
+		{"no credentials, no header", "", nil, http.StatusOK, DefaultID},
+		{"no credentials, header", "acme", nil, http.StatusOK, acme.ID},
+		{"bound key, no header", "", bound, http.StatusOK, acme.ID},
+		{"bound key, same header", "acme", bound, http.StatusOK, acme.ID},
+		{"bound key, other header", "other", bound, http.StatusForbidden, 0},
+		{"bound key, default header", DefaultSlug, bound, http.StatusForbidden, 0},
+		{"unbound key, header", "acme", unbound, http.StatusOK, acme.ID},
+		{"unbound key, no header", "", unbound, http.StatusOK, DefaultID},
+		{"unknown tenant", "nope", unbound, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		status, got := resolve(t, store, tt.header, tt.id)
+		if status != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, status, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if got != nil {
+				t.Errorf("%s: handler called with tenant %q", tt.name, got.Slug)
+			}
+			continue
+		}
+		if got == nil || got.ID != tt.tenant {
+			t.Errorf("%s: tenant %+v, want ID %d", tt.name, got, tt.tenant)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Kitrop/songGO-lib/database"
+	"github.com/lib/pq"
+)
+
+// Store хранит клиентов.
+type Store interface {
+	// Create создает клиента; ErrExists, если slug занят.
+	Create(ctx context.Context, t Tenant) (*Tenant, error)
+	// GetBySlug находит клиента; ErrNotFound, если его нет.
+	GetBySlug(ctx context.Context, slug string) (*Tenant, error)
+	List(ctx context.Context) ([]Tenant, error)
+}
+
+// MemoryStore хранит клиентов в памяти процесса. Клиент default создается сразу.
+type MemoryStore struct {
+	mu      sync.Mutex
+	tenants []Tenant
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tenants: []Tenant{{ID: DefaultID, Slug: DefaultSlug, Name: "Default", CreatedAt: time.Now()}}}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, t Tenant) (*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.tenants {
+		if existing.Slug == t.Slug {
+			return nil, ErrExists
+		}
+	}
+	t.ID = s.tenants[len(s.tenants)-1].ID + 1
+	t.CreatedAt = time.Now()
+	s.tenants = append(s.tenants, t)
+	return &t, nil
+}
+
+func (s *MemoryStore) GetBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tenants {
+		if t.Slug == slug {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Tenant(nil), s.tenants...), nil
+}
+
+// PostgresStore хранит клиентов в таблице tenants.
+type PostgresStore struct {
+	queries *database.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{queries: database.New(db)}
+}
+
+func (s *PostgresStore) Create(ctx context.Context, t Tenant) (*Tenant, error) {
+	var maxSongs sql.NullInt32
+	if t.MaxSongs != nil {
+		maxSongs = sql.NullInt32{Int32: *t.MaxSongs, Valid: true}
+	}
+	row, err := s.queries.CreateTenant(ctx, database.CreateTenantParams{Slug: t.Slug, Name: t.Name, MaxSongs: maxSongs})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromRow(row), nil
+}
+
+func (s *PostgresStore) GetBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	row, err := s.queries.GetTenantBySlug(ctx, slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fromRow(row), nil
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]Tenant, error) {
+	rows, err := s.queries.ListTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]Tenant, len(rows))
+	for i, row := range rows {
+		tenants[i] = *fromRow(row)
+	}
+	return tenants, nil
+}
+
+func fromRow(row database.Tenant) *Tenant {
+	t := &Tenant{ID: row.ID, Slug: row.Slug, Name: row.Name, CreatedAt: row.CreatedAt}
+	if row.MaxSongs.Valid {
+		t.MaxSongs = &row.MaxSongs.Int32
+	}
+	return t
+}
@@ -0,0 +1,68 @@
+// Package tenant разделяет библиотеки песен клиентов сервиса (арендаторов).
+// Клиент запроса определяется по учетным данным или заголовку X-Tenant
+// и передается хранилищу через контекст.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Клиент, которому принадлежат песни, созданные до появления клиентов,
+// и запросы без указания клиента.
+const (
+	DefaultID   int32 = 1
+	DefaultSlug       = "default"
+)
+
+// HeaderName — заголовок, в котором клиент указывается явно.
+const HeaderName = "X-Tenant"
+
+// ErrNotFound возвращается, если клиента с таким идентификатором нет.
+var ErrNotFound = errors.New("tenant not found")
+
+// ErrExists возвращается при создании клиента с занятым идентификатором.
+var ErrExists = errors.New("tenant already exists")
+
+// Tenant — клиент сервиса со своей библиотекой песен.
+type Tenant struct {
+	ID int32 `json:"id" example:"2"`
+	// Slug — идентификатор клиента в заголовке X-Tenant, ключах и токенах
+	Slug string `json:"slug" example:"acme"`
+	Name string `json:"name" example:"Acme Records"`
+	// MaxSongs — квота на число песен; nil — без ограничения
+	MaxSongs  *int32    `json:"maxSongs,omitempty" example:"10000"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidSlug проверяет идентификатор клиента: строчные латинские буквы,
+// цифры и дефис, не длиннее 63 символов. Совпадает с ограничением в таблице tenants.
+func ValidSlug(s string) bool {
+	return slugPattern.MatchString(s)
+}
+
+type tenantKey struct{}
+
+// WithTenant возвращает контекст с клиентом.
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// FromContext возвращает клиента запроса или nil.
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(tenantKey{}).(*Tenant)
+	return t
+}
+
+// ID возвращает ID клиента из контекста; без клиента — DefaultID.
+// Так команды CLI и фоновые задачи работают с библиотекой default.
+func ID(ctx context.Context) int32 {
+	if t := FromContext(ctx); t != nil {
+		return t.ID
+	}
+	return DefaultID
+}